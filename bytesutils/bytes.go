@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 
 	"github.com/Yamiyo/common/convertutils"
+	"github.com/Yamiyo/common/rlp"
 )
 
 // ToHex returns the hex representation of b, prefixed with '0x'.
@@ -246,7 +247,9 @@ func BytesAfter(b []byte) []byte {
 	return append(x, 'x')
 }
 
-// Sha1 ...
+// Sha1 returns the hex-encoded SHA-1 hash of the objects converted by
+// convertutils.ConvertToBytes. The conversion doesn't keep list boundaries,
+// use Sha1Structured for new hashes.
 func Sha1(obj []interface{}) (string, error) {
 	buf := make([]byte, 0)
 
@@ -260,3 +263,17 @@ func Sha1(obj []interface{}) (string, error) {
 
 	return ToHex(sha1[:]), nil
 }
+
+// Sha1Structured is like Sha1, but hashes the rlp.HashableEncode encoding of
+// the objects. Unlike the output of ConvertToBytes, this encoding keeps slice
+// boundaries and map keys, so objects of different shape hash differently.
+// The two functions produce different hashes for the same objects.
+func Sha1Structured(obj []interface{}) (string, error) {
+	h := sha1.New()
+	for _, o := range obj {
+		if err := rlp.HashableEncode(h, o); err != nil {
+			return "", err
+		}
+	}
+	return ToHex(h.Sum(nil)), nil
+}
@@ -54,3 +54,42 @@ func Test_Sha1(t *testing.T) {
 
 	fmt.Println(ret)
 }
+
+func Test_Sha1Structured(t *testing.T) {
+	collisions := [][2][]interface{}{
+		// Nested and flat slices.
+		{{[][]string{{"a"}, {"b"}}}, {[]string{"a", "b"}}},
+		// Maps with different keys.
+		{{map[string]uint{"x": 1}}, {map[string]uint{"y": 1}}},
+	}
+	for _, c := range collisions {
+		flatA, _ := Sha1(c[0])
+		flatB, _ := Sha1(c[1])
+		if flatA != flatB {
+			t.Fatalf("expected Sha1 collision for %v and %v", c[0], c[1])
+		}
+		hashA, err := Sha1Structured(c[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashB, err := Sha1Structured(c[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hashA == hashB {
+			t.Errorf("Sha1Structured collision for %v and %v: %s", c[0], c[1], hashA)
+		}
+	}
+
+	// Map iteration order doesn't affect the hash.
+	m := map[string]interface{}{"a": []int{1, 2}, "b": []float64{0.5}, "c": "x"}
+	first, err := Sha1Structured([]interface{}{m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if h, _ := Sha1Structured([]interface{}{m}); h != first {
+			t.Fatalf("unstable hash: %s != %s", h, first)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Command rlpdump prints RLP data in human-readable form.
+//
+// Usage:
+//
+//	rlpdump [-json | -text | -schema schema] [-bin] < input
+//
+// The input is read from stdin. It is decoded as hex if it consists of hex
+// digits only, with an optional 0x prefix and surrounding whitespace, and is
+// treated as binary RLP otherwise. Use -bin to always treat it as binary.
+//
+// By default the values are printed as a tree with offsets and sizes, see
+// rlp.Dump. With -json a single value is printed as JSON, see rlp.ToJSON.
+// With -text it is printed in the text notation of rlp.FormatText, which
+// can be pasted into test fixtures.
+// With -schema the value is decoded according to the given schema, see
+// rlp.Schema, and printed as a JSON object. Byte strings are shown in hex.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/Yamiyo/common/rlp"
+)
+
+func main() {
+	var (
+		asJSON = flag.Bool("json", false, "print the value as JSON")
+		asText = flag.Bool("text", false, "print the value in rlp.FormatText notation")
+		binary = flag.Bool("bin", false, "treat input as binary even if it looks like hex")
+		schema = flag.String("schema", "", "decode the value with `schema` and print it as JSON")
+	)
+	flag.Parse()
+
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fatal(err)
+	}
+	input := parseInput(data, *binary)
+	if *schema != "" {
+		s, err := rlp.ParseSchema(*schema)
+		if err != nil {
+			fatal(err)
+		}
+		val, err := rlp.DecodeWithSchema(input, s)
+		if err != nil {
+			fatal(err)
+		}
+		out, err := json.MarshalIndent(hexBytes(val), "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+	if *asJSON {
+		out, err := rlp.ToJSON(input)
+		if err != nil {
+			fatal(err)
+		}
+		var indented bytes.Buffer
+		json.Indent(&indented, out, "", "  ")
+		fmt.Println(indented.String())
+		return
+	}
+	if *asText {
+		out, err := rlp.FormatText(input)
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Println(out)
+		return
+	}
+	out, err := rlp.Dump(input)
+	os.Stdout.WriteString(out)
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(args ...interface{}) {
+	fmt.Fprintln(os.Stderr, append([]interface{}{"rlpdump:"}, args...)...)
+	os.Exit(1)
+}
+
+// parseInput returns the RLP bytes contained in data. Unless binary is set,
+// data holding only hex digits is decoded as hex.
+func parseInput(data []byte, binary bool) []byte {
+	if binary {
+		return data
+	}
+	text := bytes.TrimSpace(data)
+	if bytes.HasPrefix(text, []byte("0x")) || bytes.HasPrefix(text, []byte("0X")) {
+		text = text[2:]
+	}
+	dec := make([]byte, hex.DecodedLen(len(text)))
+	if _, err := hex.Decode(dec, text); err != nil || len(text) == 0 {
+		return data
+	}
+	return dec
+}
+
+// hexBytes replaces the byte slices in a value decoded by rlp.DecodeWithSchema
+// with hex strings, which are easier to read than the base64 that
+// encoding/json would produce.
+func hexBytes(val interface{}) interface{} {
+	switch v := val.(type) {
+	case []byte:
+		return "0x" + hex.EncodeToString(v)
+	case rlp.RawValue:
+		return "0x" + hex.EncodeToString(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = hexBytes(v[i])
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k := range v {
+			out[k] = hexBytes(v[k])
+		}
+		return out
+	default:
+		return val
+	}
+}
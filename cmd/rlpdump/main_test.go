@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/Yamiyo/common/rlp"
+)
+
+func TestParseInput(t *testing.T) {
+	tests := []struct {
+		data   string
+		binary bool
+		want   []byte
+	}{
+		{data: "c20102", want: []byte{0xC2, 0x01, 0x02}},
+		{data: "C20102\n", want: []byte{0xC2, 0x01, 0x02}},
+		{data: "  0xC20102 \r\n", want: []byte{0xC2, 0x01, 0x02}},
+		{data: "0Xc0", want: []byte{0xC0}},
+		// Input that isn't valid hex is used as is.
+		{data: "\xC2\x01\x02", want: []byte{0xC2, 0x01, 0x02}},
+		{data: "C2010", want: []byte("C2010")},
+		{data: "0x", want: []byte("0x")},
+		{data: "", want: []byte{}},
+		// Forced binary input.
+		{data: "c0", binary: true, want: []byte("c0")},
+	}
+	for _, test := range tests {
+		got := parseInput([]byte(test.data), test.binary)
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("parseInput(%q, %t) = %x, want %x", test.data, test.binary, got, test.want)
+		}
+	}
+}
+
+func TestHexBytes(t *testing.T) {
+	val := map[string]interface{}{
+		"a": []byte{0x01, 0xFF},
+		"b": []interface{}{rlp.RawValue{0xC0}, uint64(5), []interface{}{[]byte{}}},
+		"c": map[string]interface{}{"d": "text"},
+	}
+	out, err := json.Marshal(hexBytes(val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a":"0x01ff","b":["0xc0",5,["0x"]],"c":{"d":"text"}}`
+	if string(out) != want {
+		t.Errorf("wrong output\ngot  %s\nwant %s", out, want)
+	}
+}
@@ -0,0 +1,813 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const rlpPackagePath = "github.com/Yamiyo/common/rlp"
+
+// buildContext holds the state of a single generator run.
+type buildContext struct {
+	pkg     *types.Package
+	topType *types.Named
+
+	imports  map[string]string // import path -> package name
+	inlining map[*types.Named]bool
+	tmpCount int
+}
+
+func newBuildContext(pkg *types.Package, topType *types.Named) *buildContext {
+	ctx := &buildContext{
+		pkg:      pkg,
+		topType:  topType,
+		imports:  map[string]string{"io": "io"},
+		inlining: make(map[*types.Named]bool),
+	}
+	return ctx
+}
+
+// hasMethod reports whether the method set of typ contains the named method.
+func hasMethod(typ types.Type, name string) bool {
+	return types.NewMethodSet(typ).Lookup(nil, name) != nil
+}
+
+func (ctx *buildContext) generate() ([]byte, error) {
+	var enc, dec bytes.Buffer
+	if err := ctx.genEncodeMethod(&enc); err != nil {
+		return nil, err
+	}
+	if err := ctx.genDecodeMethod(&dec); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "// Code generated by rlpgen. DO NOT EDIT.")
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "package %s\n\n", ctx.pkg.Name())
+	// Standard library imports go first, followed by everything else.
+	var std, other []string
+	for path := range ctx.imports {
+		if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	fmt.Fprintln(&b, "import (")
+	for _, path := range std {
+		fmt.Fprintf(&b, "\t%q\n", path)
+	}
+	if len(std) > 0 && len(other) > 0 {
+		fmt.Fprintln(&b)
+	}
+	for _, path := range other {
+		fmt.Fprintf(&b, "\t%q\n", path)
+	}
+	fmt.Fprintln(&b, ")")
+	fmt.Fprintln(&b)
+	b.Write(enc.Bytes())
+	fmt.Fprintln(&b)
+	b.Write(dec.Bytes())
+
+	out, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("can't format generated code: %v\n%s", err, b.Bytes())
+	}
+	return out, nil
+}
+
+// qualify is the types.Qualifier for generated code. It records the
+// imports needed by the generated file.
+func (ctx *buildContext) qualify(p *types.Package) string {
+	if p == ctx.pkg {
+		return ""
+	}
+	ctx.imports[p.Path()] = p.Name()
+	return p.Name()
+}
+
+func (ctx *buildContext) typeString(t types.Type) string {
+	return types.TypeString(t, ctx.qualify)
+}
+
+// rlp returns the qualified name of an identifier in package rlp.
+func (ctx *buildContext) rlp(name string) string {
+	ctx.imports[rlpPackagePath] = "rlp"
+	return "rlp." + name
+}
+
+func (ctx *buildContext) tmp(prefix string) string {
+	v := fmt.Sprintf("%s%d", prefix, ctx.tmpCount)
+	ctx.tmpCount++
+	return v
+}
+
+func (ctx *buildContext) genEncodeMethod(b *bytes.Buffer) error {
+	var body bytes.Buffer
+	var err error
+	ctx.inlining[ctx.topType] = true
+	if st, ok := ctx.topType.Underlying().(*types.Struct); ok {
+		// Struct fields can be selected on the receiver directly.
+		err = ctx.genEncodeStruct(&body, "obj", ctx.topType, st)
+	} else {
+		err = ctx.genEncode(&body, "(*obj)", ctx.topType.Underlying(), rlpTags{})
+	}
+	delete(ctx.inlining, ctx.topType)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b, "func (obj *%s) EncodeRLP(_w io.Writer) error {\n", ctx.topType.Obj().Name())
+	fmt.Fprintf(b, "w := %s(_w)\n", ctx.rlp("NewEncoderBuffer"))
+	b.Write(body.Bytes())
+	fmt.Fprintln(b, "return w.Flush()")
+	fmt.Fprintln(b, "}")
+	return nil
+}
+
+func (ctx *buildContext) genDecodeMethod(b *bytes.Buffer) error {
+	var body bytes.Buffer
+	var err error
+	ctx.inlining[ctx.topType] = true
+	if st, ok := ctx.topType.Underlying().(*types.Struct); ok {
+		err = ctx.genDecodeStruct(&body, "obj", ctx.topType, st)
+	} else {
+		err = ctx.genDecode(&body, "(*obj)", ctx.topType.Underlying(), rlpTags{})
+	}
+	delete(ctx.inlining, ctx.topType)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b, "func (obj *%s) DecodeRLP(dec *%s) error {\n", ctx.topType.Obj().Name(), ctx.rlp("Stream"))
+	b.Write(body.Bytes())
+	fmt.Fprintln(b, "return nil")
+	fmt.Fprintln(b, "}")
+	return nil
+}
+
+// genEncode writes statements encoding the addressable expression v of
+// type t. The cases are checked in the same order as in rlp.makeWriter.
+func (ctx *buildContext) genEncode(b *bytes.Buffer, v string, t types.Type, ts rlpTags) error {
+	switch {
+	case isNamed(t, rlpPackagePath, "RawValue"):
+		fmt.Fprintf(b, "w.Write(%s)\n", v)
+		return nil
+	case isPointerTo(t, "math/big", "Int"):
+		fmt.Fprintf(b, "if err := w.WriteBigInt(%s); err != nil {\nreturn err\n}\n", v)
+		return nil
+	case isNamed(t, "math/big", "Int"):
+		fmt.Fprintf(b, "if err := w.WriteBigInt(&%s); err != nil {\nreturn err\n}\n", v)
+		return nil
+	case isPointerTo(t, "time", "Time"):
+		fmt.Fprintf(b, "if %s == nil {\nw.Write(%s)\n} else if err := w.WriteTime(*%s); err != nil {\nreturn err\n}\n", v, ctx.rlp("EmptyString"), v)
+		return nil
+	case isNamed(t, "time", "Time"):
+		fmt.Fprintf(b, "if err := w.WriteTime(%s); err != nil {\nreturn err\n}\n", v)
+		return nil
+	}
+
+	if ptr, ok := t.(*types.Pointer); ok {
+		nilKind := ts.nilKind
+		if !ts.nilOK {
+			nilKind = defaultNilKind(ptr.Elem())
+		}
+		fmt.Fprintf(b, "if %s == nil {\nw.Write(%s)\n} else {\n", v, ctx.rlp("Empty"+nilKind))
+		if err := ctx.genEncode(b, "(*"+v+")", ptr.Elem(), rlpTags{}); err != nil {
+			return err
+		}
+		fmt.Fprintln(b, "}")
+		return nil
+	}
+	if named, ok := t.(*types.Named); ok && named != ctx.topType && hasMethod(types.NewPointer(t), "EncodeRLP") {
+		fmt.Fprintf(b, "if err := %s.EncodeRLP(w); err != nil {\nreturn err\n}\n", v)
+		return nil
+	}
+	if isBigEndianInteger(t) {
+		fmt.Fprintf(b, "w.WriteBigEndian(%s)\n", addrOf(v))
+		return nil
+	}
+	if named, ok := t.(*types.Named); ok && ctx.inlining[named] {
+		if named != ctx.topType {
+			return fmt.Errorf("recursive type %s is not supported", shortName(named))
+		}
+		fmt.Fprintf(b, "if err := %s.EncodeRLP(w); err != nil {\nreturn err\n}\n", v)
+		return nil
+	}
+
+	if err := checkByteElem(t); err != nil {
+		return err
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return ctx.genEncodeBasic(b, v, t, u)
+	case *types.Slice:
+		if isByteType(u.Elem()) {
+			fmt.Fprintf(b, "w.WriteBytes(%s)\n", v)
+			return nil
+		}
+		return ctx.genEncodeList(b, v, u.Elem(), ts)
+	case *types.Array:
+		if isByteType(u.Elem()) {
+			fmt.Fprintf(b, "w.WriteBytes(%s[:])\n", v)
+			return nil
+		}
+		return ctx.genEncodeList(b, v, u.Elem(), ts)
+	case *types.Struct:
+		return ctx.withInlining(t, func() error {
+			return ctx.genEncodeStruct(b, v, t, u)
+		})
+	case *types.Map, *types.Interface:
+		// Encode through a pointer so that nil interfaces reach
+		// the reflective interface writer.
+		fmt.Fprintf(b, "if err := %s(w, &%s); err != nil {\nreturn err\n}\n", ctx.rlp("Encode"), v)
+		return nil
+	}
+	return fmt.Errorf("type %s is not RLP-serializable", shortName(t))
+}
+
+func (ctx *buildContext) genEncodeBasic(b *bytes.Buffer, v string, t types.Type, u *types.Basic) error {
+	var method, conv string
+	switch info := u.Info(); {
+	case u.Kind() == types.Bool:
+		method, conv = "WriteBool", "bool"
+	case u.Kind() == types.String:
+		method, conv = "WriteString", "string"
+	case info&types.IsUnsigned != 0:
+		method, conv = "WriteUint64", "uint64"
+	case info&types.IsInteger != 0:
+		method, conv = "WriteInt64", "int64"
+	case info&types.IsFloat != 0:
+		method, conv = "WriteFloat64", "float64"
+	default:
+		return fmt.Errorf("type %s is not RLP-serializable", shortName(t))
+	}
+	if types.Identical(t, types.Universe.Lookup(conv).Type()) {
+		fmt.Fprintf(b, "w.%s(%s)\n", method, v)
+	} else {
+		fmt.Fprintf(b, "w.%s(%s(%s))\n", method, conv, v)
+	}
+	return nil
+}
+
+func (ctx *buildContext) genEncodeList(b *bytes.Buffer, v string, elem types.Type, ts rlpTags) error {
+	var list string
+	if !ts.tail {
+		list = ctx.tmp("_tmp")
+		fmt.Fprintf(b, "%s := w.List()\n", list)
+	}
+	i := ctx.tmp("_i")
+	fmt.Fprintf(b, "for %s := range %s {\n", i, v)
+	if err := ctx.genEncode(b, v+"["+i+"]", elem, rlpTags{}); err != nil {
+		return err
+	}
+	fmt.Fprintln(b, "}")
+	if !ts.tail {
+		fmt.Fprintf(b, "w.ListEnd(%s)\n", list)
+	}
+	return nil
+}
+
+func (ctx *buildContext) genEncodeStruct(b *bytes.Buffer, v string, t types.Type, st *types.Struct) error {
+	fields, err := structFields(t, st)
+	if err != nil {
+		return err
+	}
+	// Trailing optional fields are only written if they or any
+	// following field hold a non-zero value.
+	firstOpt := firstOptionalField(fields)
+	var nonZero []string
+	for _, f := range fields[firstOpt:] {
+		check, err := ctx.nonZeroCheck(v+"."+f.name, f.typ)
+		if err != nil {
+			return fmt.Errorf("%v (struct field %s.%s)", err, shortName(t), f.name)
+		}
+		tmp := ctx.tmp("_tmp")
+		fmt.Fprintf(b, "%s := %s\n", tmp, check)
+		nonZero = append(nonZero, tmp)
+	}
+	list := ctx.tmp("_tmp")
+	fmt.Fprintf(b, "%s := w.List()\n", list)
+	if hasMethod(types.NewPointer(t), "RLPVersion") {
+		fmt.Fprintf(b, "w.WriteUint64(uint64(%s.RLPVersion()))\n", v)
+	}
+	for i, f := range fields {
+		if i >= firstOpt {
+			fmt.Fprintf(b, "if %s {\n", strings.Join(nonZero[i-firstOpt:], " || "))
+		}
+		if err := ctx.genEncode(b, v+"."+f.name, f.typ, f.tags); err != nil {
+			return fmt.Errorf("%v (struct field %s.%s)", err, shortName(t), f.name)
+		}
+		if i >= firstOpt {
+			fmt.Fprintln(b, "}")
+		}
+	}
+	fmt.Fprintf(b, "w.ListEnd(%s)\n", list)
+	return nil
+}
+
+// nonZeroCheck returns an expression reporting whether v is non-zero, in
+// the sense of reflect.Value.IsZero.
+func (ctx *buildContext) nonZeroCheck(v string, t types.Type) (string, error) {
+	switch u := t.Underlying().(type) {
+	case *types.Pointer, *types.Slice, *types.Map, *types.Interface, *types.Chan, *types.Signature:
+		return v + " != nil", nil
+	case *types.Basic:
+		switch info := u.Info(); {
+		case info&types.IsBoolean != 0:
+			if types.Identical(t, types.Typ[types.Bool]) {
+				return v, nil
+			}
+			return "bool(" + v + ")", nil
+		case info&types.IsString != 0:
+			return v + ` != ""`, nil
+		case info&types.IsNumeric != 0:
+			return v + " != 0", nil
+		}
+	case *types.Struct, *types.Array:
+		if types.Comparable(t) {
+			return v + " != (" + ctx.typeString(t) + "{})", nil
+		}
+	}
+	return "", fmt.Errorf("optional field of type %s is not supported", shortName(t))
+}
+
+// zeroValue returns an expression for the zero value of t.
+func (ctx *buildContext) zeroValue(t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch info := u.Info(); {
+		case info&types.IsBoolean != 0:
+			return "false"
+		case info&types.IsString != 0:
+			return `""`
+		default:
+			return "0"
+		}
+	case *types.Struct, *types.Array:
+		return ctx.typeString(t) + "{}"
+	}
+	return "nil"
+}
+
+// genDecode writes statements decoding into the addressable expression v
+// of type t. The cases are checked in the same order as in rlp.makeDecoder.
+func (ctx *buildContext) genDecode(b *bytes.Buffer, v string, t types.Type, ts rlpTags) error {
+	switch {
+	case isNamed(t, rlpPackagePath, "RawValue"):
+		tmp := ctx.tmp("_tmp")
+		fmt.Fprintf(b, "%s, err := dec.Raw()\nif err != nil {\nreturn err\n}\n%s = %s\n", tmp, v, tmp)
+		return nil
+	case isPointerTo(t, "math/big", "Int"):
+		tmp := ctx.tmp("_tmp")
+		fmt.Fprintf(b, "%s, err := dec.BigInt()\nif err != nil {\nreturn err\n}\n%s = %s\n", tmp, v, tmp)
+		return nil
+	case isNamed(t, "math/big", "Int"):
+		tmp := ctx.tmp("_tmp")
+		fmt.Fprintf(b, "%s, err := dec.BigInt()\nif err != nil {\nreturn err\n}\n%s.Set(%s)\n", tmp, v, tmp)
+		return nil
+	case isPointerTo(t, "time", "Time"):
+		tmp := ctx.tmp("_tmp")
+		fmt.Fprintf(b, "%s, err := dec.Time()\nif err != nil {\nreturn err\n}\n", tmp)
+		fmt.Fprintf(b, "if %s == nil {\n%s = new(%s)\n}\n*%s = %s\n", v, v, ctx.typeString(t.(*types.Pointer).Elem()), v, tmp)
+		return nil
+	case isNamed(t, "time", "Time"):
+		tmp := ctx.tmp("_tmp")
+		fmt.Fprintf(b, "%s, err := dec.Time()\nif err != nil {\nreturn err\n}\n%s = %s\n", tmp, v, tmp)
+		return nil
+	}
+
+	if ptr, ok := t.(*types.Pointer); ok {
+		return ctx.genDecodePtr(b, v, ptr, ts)
+	}
+	if named, ok := t.(*types.Named); ok && named != ctx.topType && hasMethod(types.NewPointer(t), "DecodeRLP") {
+		fmt.Fprintf(b, "if err := %s.DecodeRLP(dec); err != nil {\nreturn err\n}\n", v)
+		return nil
+	}
+	if isBigEndianInteger(t) {
+		fmt.Fprintf(b, "if err := dec.ReadBigEndian(%s); err != nil {\nreturn err\n}\n", addrOf(v))
+		return nil
+	}
+	if named, ok := t.(*types.Named); ok && ctx.inlining[named] {
+		if named != ctx.topType {
+			return fmt.Errorf("recursive type %s is not supported", shortName(named))
+		}
+		fmt.Fprintf(b, "if err := %s.DecodeRLP(dec); err != nil {\nreturn err\n}\n", v)
+		return nil
+	}
+
+	if err := checkByteElem(t); err != nil {
+		return err
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return ctx.genDecodeBasic(b, v, t, u)
+	case *types.Slice:
+		if isByteType(u.Elem()) {
+			tmp := ctx.tmp("_tmp")
+			fmt.Fprintf(b, "%s, err := dec.Bytes()\nif err != nil {\nreturn err\n}\n%s = %s\n", tmp, v, tmp)
+			return nil
+		}
+		return ctx.genDecodeSlice(b, v, t, u.Elem(), ts)
+	case *types.Array:
+		if isByteType(u.Elem()) {
+			fmt.Fprintf(b, "if err := dec.ReadBytes(%s[:]); err != nil {\nreturn err\n}\n", v)
+			return nil
+		}
+		fmt.Fprintf(b, "if _, err := dec.List(); err != nil {\nreturn err\n}\n")
+		i := ctx.tmp("_i")
+		fmt.Fprintf(b, "for %s := range %s {\n", i, v)
+		if err := ctx.genDecode(b, v+"["+i+"]", u.Elem(), rlpTags{}); err != nil {
+			return err
+		}
+		fmt.Fprintln(b, "}")
+		fmt.Fprintf(b, "if err := dec.ListEnd(); err != nil {\nreturn err\n}\n")
+		return nil
+	case *types.Struct:
+		return ctx.withInlining(t, func() error {
+			return ctx.genDecodeStruct(b, v, t, u)
+		})
+	case *types.Map, *types.Interface:
+		fmt.Fprintf(b, "if err := dec.Decode(&%s); err != nil {\nreturn err\n}\n", v)
+		return nil
+	}
+	return fmt.Errorf("type %s is not RLP-serializable", shortName(t))
+}
+
+func (ctx *buildContext) genDecodePtr(b *bytes.Buffer, v string, ptr *types.Pointer, ts rlpTags) error {
+	if !ts.nilOK {
+		fmt.Fprintf(b, "if %s == nil {\n%s = new(%s)\n}\n", v, v, ctx.typeString(ptr.Elem()))
+		return ctx.genDecode(b, "(*"+v+")", ptr.Elem(), rlpTags{})
+	}
+	// Empty values of the tagged kind decode as nil. They are consumed
+	// explicitly so that the stream advances to the next value.
+	kind, size := ctx.tmp("_kind"), ctx.tmp("_size")
+	fmt.Fprintf(b, "%s, %s, err := dec.Kind()\nif err != nil {\nreturn err\n}\n", kind, size)
+	fmt.Fprintf(b, "if %s != %s && %s == 0 {\n", kind, ctx.rlp("Byte"), size)
+	if ts.nilKind == "String" {
+		fmt.Fprintf(b, "if %s != %s {\nreturn %s\n}\n", kind, ctx.rlp("String"), ctx.rlp("ErrExpectedString"))
+		fmt.Fprintf(b, "if _, err := dec.Bytes(); err != nil {\nreturn err\n}\n")
+	} else {
+		fmt.Fprintf(b, "if %s != %s {\nreturn %s\n}\n", kind, ctx.rlp("List"), ctx.rlp("ErrExpectedList"))
+		fmt.Fprintf(b, "if _, err := dec.List(); err != nil {\nreturn err\n}\n")
+		fmt.Fprintf(b, "if err := dec.ListEnd(); err != nil {\nreturn err\n}\n")
+	}
+	fmt.Fprintf(b, "%s = nil\n} else {\n", v)
+	fmt.Fprintf(b, "if %s == nil {\n%s = new(%s)\n}\n", v, v, ctx.typeString(ptr.Elem()))
+	if err := ctx.genDecode(b, "(*"+v+")", ptr.Elem(), rlpTags{}); err != nil {
+		return err
+	}
+	fmt.Fprintln(b, "}")
+	return nil
+}
+
+func (ctx *buildContext) genDecodeBasic(b *bytes.Buffer, v string, t types.Type, u *types.Basic) error {
+	var method string
+	switch u.Kind() {
+	case types.Bool:
+		method = "Bool"
+	case types.String:
+		method = "Bytes"
+	case types.Uint, types.Uint64, types.Uintptr:
+		method = "Uint64"
+	case types.Uint32:
+		method = "Uint32"
+	case types.Uint16:
+		method = "Uint16"
+	case types.Uint8:
+		method = "Uint8"
+	case types.Int, types.Int64:
+		method = "Int64"
+	case types.Int32:
+		method = "Int32"
+	case types.Int16:
+		method = "Int16"
+	case types.Int8:
+		method = "Int8"
+	case types.Float64:
+		method = "Float64"
+	case types.Float32:
+		method = "Float32"
+	default:
+		return fmt.Errorf("type %s is not RLP-serializable", shortName(t))
+	}
+	tmp := ctx.tmp("_tmp")
+	fmt.Fprintf(b, "%s, err := dec.%s()\nif err != nil {\nreturn err\n}\n", tmp, method)
+	// Stream methods return the basic type named like the method,
+	// except for Bytes which is used for strings.
+	if result := types.Universe.Lookup(strings.ToLower(method)); result != nil && types.Identical(result.Type(), t) {
+		fmt.Fprintf(b, "%s = %s\n", v, tmp)
+	} else {
+		fmt.Fprintf(b, "%s = %s(%s)\n", v, ctx.typeString(t), tmp)
+	}
+	return nil
+}
+
+func (ctx *buildContext) genDecodeSlice(b *bytes.Buffer, v string, t, elem types.Type, ts rlpTags) error {
+	if !ts.tail {
+		fmt.Fprintf(b, "if _, err := dec.List(); err != nil {\nreturn err\n}\n")
+	}
+	slice, e := ctx.tmp("_tmp"), ctx.tmp("_elem")
+	fmt.Fprintf(b, "%s := %s{}\n", slice, ctx.typeString(t))
+	fmt.Fprintf(b, "for dec.MoreDataInList() {\n")
+	fmt.Fprintf(b, "var %s %s\n", e, ctx.typeString(elem))
+	if err := ctx.genDecode(b, e, elem, rlpTags{}); err != nil {
+		return err
+	}
+	fmt.Fprintf(b, "%s = append(%s, %s)\n", slice, slice, e)
+	fmt.Fprintln(b, "}")
+	if !ts.tail {
+		fmt.Fprintf(b, "if err := dec.ListEnd(); err != nil {\nreturn err\n}\n")
+	}
+	fmt.Fprintf(b, "%s = %s\n", v, slice)
+	return nil
+}
+
+func (ctx *buildContext) genDecodeStruct(b *bytes.Buffer, v string, t types.Type, st *types.Struct) error {
+	fields, err := structFields(t, st)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b, "if _, err := dec.List(); err != nil {\nreturn err\n}\n")
+	// Versioned types start with the version. Old versions are decoded
+	// by the registered migration.
+	versioned := hasMethod(types.NewPointer(t), "RLPVersion")
+	if versioned {
+		ptr := "&" + v
+		if t == ctx.topType {
+			ptr = v // the receiver is a pointer already
+		}
+		version := ctx.tmp("_tmp")
+		fmt.Fprintf(b, "if %s, err := dec.Uint64(); err != nil {\nreturn err\n", version)
+		fmt.Fprintf(b, "} else if %s != uint64(%s.RLPVersion()) {\n", version, v)
+		fmt.Fprintf(b, "if err := %s(dec, uint(%s), %s); err != nil {\nreturn err\n}\n", ctx.rlp("Migrate"), version, ptr)
+		fmt.Fprintf(b, "} else {\n")
+	}
+	var optional []int
+	for i, f := range fields {
+		if f.tags.optional {
+			fmt.Fprintf(b, "if dec.MoreDataInList() {\n")
+			optional = append(optional, i)
+		}
+		if err := ctx.genDecode(b, v+"."+f.name, f.typ, f.tags); err != nil {
+			return fmt.Errorf("%v (struct field %s.%s)", err, shortName(t), f.name)
+		}
+	}
+	// When the input ends before an optional field, that field and all
+	// fields after it are reset to zero.
+	for j := len(optional) - 1; j >= 0; j-- {
+		fmt.Fprintf(b, "} else {\n")
+		for _, f := range fields[optional[j]:] {
+			fmt.Fprintf(b, "%s.%s = %s\n", v, f.name, ctx.zeroValue(f.typ))
+		}
+		fmt.Fprintln(b, "}")
+	}
+	fmt.Fprintf(b, "if err := dec.ListEnd(); err != nil {\nreturn err\n}\n")
+	if versioned {
+		fmt.Fprintln(b, "}")
+	}
+	return nil
+}
+
+// withInlining runs fn while t is marked as being inlined, so that
+// recursive references to t can be detected.
+func (ctx *buildContext) withInlining(t types.Type, fn func() error) error {
+	named, ok := t.(*types.Named)
+	if !ok || named == ctx.topType {
+		return fn()
+	}
+	ctx.inlining[named] = true
+	defer delete(ctx.inlining, named)
+	return fn()
+}
+
+// rlpTags mirrors the struct tags understood by package rlp.
+type rlpTags struct {
+	nilOK    bool
+	nilKind  string // "String" or "List"
+	tail     bool
+	optional bool
+	ignored  bool
+	inline   bool
+}
+
+type structField struct {
+	name string // selector path, e.g. "Base.ID" for inlined fields
+	typ  types.Type
+	tags rlpTags
+}
+
+// structFields returns the encoded fields of a struct, applying the same
+// rules as rlp's structFields and parseStructTag.
+func structFields(t types.Type, st *types.Struct) ([]structField, error) {
+	var fs fieldScanner
+	if err := fs.scan(t, st, ""); err != nil {
+		return nil, err
+	}
+	return fs.fields, nil
+}
+
+// fieldScanner collects the encoded fields of a struct. Fields of embedded
+// structs with the "inline" tag are collected in place.
+type fieldScanner struct {
+	fields        []structField
+	firstOptional string
+	tail          string
+}
+
+func (fs *fieldScanner) scan(t types.Type, st *types.Struct, prefix string) error {
+	lastPublic := 0
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i).Exported() {
+			lastPublic = i
+		}
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if !f.Exported() {
+			continue
+		}
+		ts, err := parseStructTag(t, st, i, lastPublic)
+		if err != nil {
+			return err
+		}
+		if ts.ignored {
+			continue
+		}
+		if fs.tail != "" {
+			return fmt.Errorf("invalid struct tag \"tail\" for %s.%s (must be on last field)", shortName(t), fs.tail)
+		}
+		if ts.inline {
+			if err := fs.scan(t, f.Type().Underlying().(*types.Struct), prefix+f.Name()+"."); err != nil {
+				return err
+			}
+			continue
+		}
+		if ts.optional || ts.tail {
+			if fs.firstOptional == "" {
+				fs.firstOptional = f.Name()
+			}
+		} else if fs.firstOptional != "" {
+			return fmt.Errorf("invalid struct tag %q for %s.%s (must be optional because preceding field %q is optional)", reflect.StructTag(st.Tag(i)).Get("rlp"), shortName(t), f.Name(), fs.firstOptional)
+		}
+		if ts.tail {
+			fs.tail = f.Name()
+		}
+		fs.fields = append(fs.fields, structField{prefix + f.Name(), f.Type(), ts})
+	}
+	return nil
+}
+
+// firstOptionalField returns the index of the first field with the
+// "optional" tag, or len(fields) if there is none.
+func firstOptionalField(fields []structField) int {
+	for i, f := range fields {
+		if f.tags.optional {
+			return i
+		}
+	}
+	return len(fields)
+}
+
+func parseStructTag(t types.Type, st *types.Struct, fi, lastPublic int) (rlpTags, error) {
+	f := st.Field(fi)
+	var ts rlpTags
+	for _, tag := range strings.Split(reflect.StructTag(st.Tag(fi)).Get("rlp"), ",") {
+		switch tag = strings.TrimSpace(tag); tag {
+		case "":
+		case "-":
+			ts.ignored = true
+		case "nil", "nilString", "nilList":
+			ts.nilOK = true
+			ptr, ok := f.Type().(*types.Pointer)
+			if !ok {
+				return ts, fmt.Errorf("invalid struct tag %q for %s.%s (field is not a pointer)", tag, shortName(t), f.Name())
+			}
+			switch tag {
+			case "nil":
+				ts.nilKind = defaultNilKind(ptr.Elem())
+			case "nilString":
+				ts.nilKind = "String"
+			case "nilList":
+				ts.nilKind = "List"
+			}
+		case "optional":
+			ts.optional = true
+			if ts.tail {
+				return ts, fmt.Errorf("invalid struct tag %q for %s.%s (also has \"tail\" tag)", tag, shortName(t), f.Name())
+			}
+			if ts.inline {
+				return ts, fmt.Errorf("invalid struct tag %q for %s.%s (also has \"inline\" tag)", tag, shortName(t), f.Name())
+			}
+		case "tail":
+			ts.tail = true
+			if ts.optional {
+				return ts, fmt.Errorf("invalid struct tag %q for %s.%s (also has \"optional\" tag)", tag, shortName(t), f.Name())
+			}
+			if fi != lastPublic {
+				return ts, fmt.Errorf("invalid struct tag %q for %s.%s (must be on last field)", tag, shortName(t), f.Name())
+			}
+			if _, ok := f.Type().Underlying().(*types.Slice); !ok {
+				return ts, fmt.Errorf("invalid struct tag %q for %s.%s (field type is not slice)", tag, shortName(t), f.Name())
+			}
+		case "inline":
+			ts.inline = true
+			if _, ok := f.Type().Underlying().(*types.Struct); !ok || !f.Embedded() {
+				return ts, fmt.Errorf("invalid struct tag %q for %s.%s (field is not an embedded struct)", tag, shortName(t), f.Name())
+			}
+			if ts.optional {
+				return ts, fmt.Errorf("invalid struct tag %q for %s.%s (also has \"optional\" tag)", tag, shortName(t), f.Name())
+			}
+		default:
+			return ts, fmt.Errorf("unknown struct tag %q on %s.%s", tag, shortName(t), f.Name())
+		}
+	}
+	return ts, nil
+}
+
+// defaultNilKind determines whether a nil pointer to typ encodes/decodes
+// as an empty string or empty list.
+func defaultNilKind(typ types.Type) string {
+	if isBigEndianInteger(typ) {
+		return "String"
+	}
+	switch u := typ.Underlying().(type) {
+	case *types.Basic:
+		if u.Info()&(types.IsUnsigned|types.IsString|types.IsBoolean) != 0 {
+			return "String"
+		}
+	case *types.Slice:
+		if isByteType(u.Elem()) {
+			return "String"
+		}
+	case *types.Array:
+		if isByteType(u.Elem()) {
+			return "String"
+		}
+	}
+	return "List"
+}
+
+// isBigEndianInteger reports whether t implements rlp.BigEndianInteger.
+func isBigEndianInteger(t types.Type) bool {
+	ptr := types.NewPointer(t)
+	return hasMethod(ptr, "BigEndianSize") && hasMethod(ptr, "PutBigEndian") && hasMethod(ptr, "SetBigEndian")
+}
+
+// addrOf returns an expression for the address of v.
+func addrOf(v string) string {
+	if strings.HasPrefix(v, "(*") && strings.HasSuffix(v, ")") {
+		return v[2 : len(v)-1]
+	}
+	return "&" + v
+}
+
+// checkByteElem rejects slices and arrays of named byte types. Package rlp
+// encodes them as strings, but they can't be converted to []byte.
+func checkByteElem(t types.Type) error {
+	var elem types.Type
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		elem = u.Elem()
+	case *types.Array:
+		elem = u.Elem()
+	default:
+		return nil
+	}
+	if basic, ok := elem.Underlying().(*types.Basic); ok && basic.Kind() == types.Uint8 && !isByteType(elem) {
+		if !hasMethod(elem, "EncodeRLP") {
+			return fmt.Errorf("type %s with named byte element type is not supported", shortName(t))
+		}
+	}
+	return nil
+}
+
+func isByteType(t types.Type) bool {
+	return types.Identical(t, types.Typ[types.Uint8])
+}
+
+func isNamed(t types.Type, pkgPath, name string) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == pkgPath && obj.Name() == name
+}
+
+func isPointerTo(t types.Type, pkgPath, name string) bool {
+	ptr, ok := t.(*types.Pointer)
+	return ok && isNamed(ptr.Elem(), pkgPath, name)
+}
+
+// shortName formats t for error messages, qualifying names with the
+// package name instead of the full import path.
+func shortName(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string { return p.Name() })
+}
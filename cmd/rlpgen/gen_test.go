@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// TestGeneratedCodeUpToDate checks that the committed generated code in
+// internal/gentest matches the current generator output.
+func TestGeneratedCodeUpToDate(t *testing.T) {
+	out := filepath.Join("internal", "gentest", "types_rlp.go")
+	code, err := generate(filepath.Join("internal", "gentest"), "Record", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(code, want) {
+		t.Errorf("%s is out of date, run go generate in internal/gentest", out)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		typ, err string
+	}{
+		{"Recursive", "recursive type invalid.Node is not supported (struct field invalid.Node.Child) (struct field invalid.Recursive.Next)"},
+		{"NamedBytes", "type []invalid.NamedByte with named byte element type is not supported (struct field invalid.NamedBytes.B)"},
+		{"BadTail", `invalid struct tag "tail" for invalid.BadTail.A (must be on last field)`},
+		{"BadNil", `invalid struct tag "nil" for invalid.BadNil.A (field is not a pointer)`},
+		{"Unsupported", "type chan int is not RLP-serializable (struct field invalid.Unsupported.C)"},
+		{"UnknownTag", `unknown struct tag "foo" on invalid.UnknownTag.A`},
+		{"BadOptional", `invalid struct tag "" for invalid.BadOptional.B (must be optional because preceding field "A" is optional)`},
+		{"OptionalTail", `invalid struct tag "tail" for invalid.OptionalTail.A (also has "optional" tag)`},
+		{"BadInline", `invalid struct tag "inline" for invalid.BadInline.A (field is not an embedded struct)`},
+		{"InlineTail", `invalid struct tag "tail" for invalid.InlineTail.T (must be on last field)`},
+		{"UnsupportedOptional", "optional field of type struct{D []uint} is not supported (struct field invalid.UnsupportedOptional.C)"},
+		{"Missing", "type Missing not found in package github.com/Yamiyo/common/cmd/rlpgen/testdata/invalid"},
+	}
+	dir := filepath.Join("testdata", "invalid")
+	for _, test := range tests {
+		_, err := generate(dir, test.typ, "")
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: error mismatch\ngot  %v\nwant %s", test.typ, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package gentest contains a type with rlpgen-generated methods. Its tests
+// check that the generated code agrees with the reflection-based codec.
+package gentest
+
+import (
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/Yamiyo/common/rlp"
+)
+
+//go:generate go run github.com/Yamiyo/common/cmd/rlpgen -type Record -out types_rlp.go
+
+// Record uses every kind of field supported by rlpgen.
+type Record struct {
+	Flag    bool
+	Small   uint8
+	Count   uint32
+	Size    uint
+	Delta   int64
+	Neg     int
+	Ratio   float64
+	Name    string
+	Label   Label
+	Data    []byte
+	Hash    [4]byte
+	Amount  *big.Int
+	Total   big.Int
+	Created time.Time
+	Updated *time.Time
+	Raw     rlp.RawValue
+	Items   []Item
+	Matrix  [2][]uint16
+	Ptr     *Item
+	NilItem *Item   `rlp:"nil"`
+	NilStr  *[]uint `rlp:"nilString"`
+	NilList *uint64 `rlp:"nilList"`
+	Attrs   map[string]uint
+	Any     interface{}
+	Custom  Custom
+	Doc     Doc
+	Wide    rlp.Uint256
+	WidePtr *rlp.Uint256
+	Meta    `rlp:"inline"`
+	Version uint64   `rlp:"optional"`
+	Level   float64  `rlp:"optional"`
+	Tag     Label    `rlp:"optional"`
+	Ignored uint     `rlp:"-"`
+	private uint     //lint:ignore U1000 unused field required for testing purposes.
+	Rest    []string `rlp:"tail"`
+}
+
+// Label is a named string type.
+type Label string
+
+// Item is encoded inline by the generated code.
+type Item struct {
+	ID   uint64
+	Tags []string
+	Sub  struct{ A, B uint }
+}
+
+// Meta is embedded in Record and flattened into its list.
+type Meta struct {
+	Owner string
+	Rev   uint
+}
+
+// Doc is a versioned type encoded inline by the generated code.
+type Doc struct {
+	Title string
+	Pages uint
+}
+
+// RLPVersion implements rlp.Versioned.
+func (Doc) RLPVersion() uint { return 2 }
+
+// docV1 is version 1 of Doc, which had no page count.
+type docV1 struct {
+	Title string
+}
+
+func init() {
+	rlp.RegisterMigration(1, func(old *docV1, cur *Doc) error {
+		cur.Title = old.Title
+		cur.Pages = 1
+		return nil
+	})
+}
+
+// Custom implements rlp.Encoder and rlp.Decoder by hand.
+type Custom struct {
+	V uint
+}
+
+// EncodeRLP encodes c as a list [V, V].
+func (c *Custom) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, []uint{c.V, c.V})
+}
+
+// DecodeRLP decodes the list written by EncodeRLP.
+func (c *Custom) DecodeRLP(s *rlp.Stream) error {
+	var v []uint
+	if err := s.Decode(&v); err != nil {
+		return err
+	}
+	if len(v) != 2 {
+		return rlp.ErrExpectedList
+	}
+	c.V = v[0]
+	return nil
+}
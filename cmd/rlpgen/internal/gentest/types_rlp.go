@@ -0,0 +1,592 @@
+// Code generated by rlpgen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"io"
+	"time"
+
+	"github.com/Yamiyo/common/rlp"
+)
+
+func (obj *Record) EncodeRLP(_w io.Writer) error {
+	w := rlp.NewEncoderBuffer(_w)
+	_tmp0 := obj.Version != 0
+	_tmp1 := obj.Level != 0
+	_tmp2 := obj.Tag != ""
+	_tmp3 := obj.Rest != nil
+	_tmp4 := w.List()
+	w.WriteBool(obj.Flag)
+	w.WriteUint64(uint64(obj.Small))
+	w.WriteUint64(uint64(obj.Count))
+	w.WriteUint64(uint64(obj.Size))
+	w.WriteInt64(obj.Delta)
+	w.WriteInt64(int64(obj.Neg))
+	w.WriteFloat64(obj.Ratio)
+	w.WriteString(obj.Name)
+	w.WriteString(string(obj.Label))
+	w.WriteBytes(obj.Data)
+	w.WriteBytes(obj.Hash[:])
+	if err := w.WriteBigInt(obj.Amount); err != nil {
+		return err
+	}
+	if err := w.WriteBigInt(&obj.Total); err != nil {
+		return err
+	}
+	if err := w.WriteTime(obj.Created); err != nil {
+		return err
+	}
+	if obj.Updated == nil {
+		w.Write(rlp.EmptyString)
+	} else if err := w.WriteTime(*obj.Updated); err != nil {
+		return err
+	}
+	w.Write(obj.Raw)
+	_tmp5 := w.List()
+	for _i6 := range obj.Items {
+		_tmp7 := w.List()
+		w.WriteUint64(obj.Items[_i6].ID)
+		_tmp8 := w.List()
+		for _i9 := range obj.Items[_i6].Tags {
+			w.WriteString(obj.Items[_i6].Tags[_i9])
+		}
+		w.ListEnd(_tmp8)
+		_tmp10 := w.List()
+		w.WriteUint64(uint64(obj.Items[_i6].Sub.A))
+		w.WriteUint64(uint64(obj.Items[_i6].Sub.B))
+		w.ListEnd(_tmp10)
+		w.ListEnd(_tmp7)
+	}
+	w.ListEnd(_tmp5)
+	_tmp11 := w.List()
+	for _i12 := range obj.Matrix {
+		_tmp13 := w.List()
+		for _i14 := range obj.Matrix[_i12] {
+			w.WriteUint64(uint64(obj.Matrix[_i12][_i14]))
+		}
+		w.ListEnd(_tmp13)
+	}
+	w.ListEnd(_tmp11)
+	if obj.Ptr == nil {
+		w.Write(rlp.EmptyList)
+	} else {
+		_tmp15 := w.List()
+		w.WriteUint64((*obj.Ptr).ID)
+		_tmp16 := w.List()
+		for _i17 := range (*obj.Ptr).Tags {
+			w.WriteString((*obj.Ptr).Tags[_i17])
+		}
+		w.ListEnd(_tmp16)
+		_tmp18 := w.List()
+		w.WriteUint64(uint64((*obj.Ptr).Sub.A))
+		w.WriteUint64(uint64((*obj.Ptr).Sub.B))
+		w.ListEnd(_tmp18)
+		w.ListEnd(_tmp15)
+	}
+	if obj.NilItem == nil {
+		w.Write(rlp.EmptyList)
+	} else {
+		_tmp19 := w.List()
+		w.WriteUint64((*obj.NilItem).ID)
+		_tmp20 := w.List()
+		for _i21 := range (*obj.NilItem).Tags {
+			w.WriteString((*obj.NilItem).Tags[_i21])
+		}
+		w.ListEnd(_tmp20)
+		_tmp22 := w.List()
+		w.WriteUint64(uint64((*obj.NilItem).Sub.A))
+		w.WriteUint64(uint64((*obj.NilItem).Sub.B))
+		w.ListEnd(_tmp22)
+		w.ListEnd(_tmp19)
+	}
+	if obj.NilStr == nil {
+		w.Write(rlp.EmptyString)
+	} else {
+		_tmp23 := w.List()
+		for _i24 := range *obj.NilStr {
+			w.WriteUint64(uint64((*obj.NilStr)[_i24]))
+		}
+		w.ListEnd(_tmp23)
+	}
+	if obj.NilList == nil {
+		w.Write(rlp.EmptyList)
+	} else {
+		w.WriteUint64((*obj.NilList))
+	}
+	if err := rlp.Encode(w, &obj.Attrs); err != nil {
+		return err
+	}
+	if err := rlp.Encode(w, &obj.Any); err != nil {
+		return err
+	}
+	if err := obj.Custom.EncodeRLP(w); err != nil {
+		return err
+	}
+	_tmp25 := w.List()
+	w.WriteUint64(uint64(obj.Doc.RLPVersion()))
+	w.WriteString(obj.Doc.Title)
+	w.WriteUint64(uint64(obj.Doc.Pages))
+	w.ListEnd(_tmp25)
+	w.WriteBigEndian(&obj.Wide)
+	if obj.WidePtr == nil {
+		w.Write(rlp.EmptyString)
+	} else {
+		w.WriteBigEndian(obj.WidePtr)
+	}
+	w.WriteString(obj.Meta.Owner)
+	w.WriteUint64(uint64(obj.Meta.Rev))
+	if _tmp0 || _tmp1 || _tmp2 || _tmp3 {
+		w.WriteUint64(obj.Version)
+	}
+	if _tmp1 || _tmp2 || _tmp3 {
+		w.WriteFloat64(obj.Level)
+	}
+	if _tmp2 || _tmp3 {
+		w.WriteString(string(obj.Tag))
+	}
+	if _tmp3 {
+		for _i26 := range obj.Rest {
+			w.WriteString(obj.Rest[_i26])
+		}
+	}
+	w.ListEnd(_tmp4)
+	return w.Flush()
+}
+
+func (obj *Record) DecodeRLP(dec *rlp.Stream) error {
+	if _, err := dec.List(); err != nil {
+		return err
+	}
+	_tmp27, err := dec.Bool()
+	if err != nil {
+		return err
+	}
+	obj.Flag = _tmp27
+	_tmp28, err := dec.Uint8()
+	if err != nil {
+		return err
+	}
+	obj.Small = _tmp28
+	_tmp29, err := dec.Uint32()
+	if err != nil {
+		return err
+	}
+	obj.Count = _tmp29
+	_tmp30, err := dec.Uint64()
+	if err != nil {
+		return err
+	}
+	obj.Size = uint(_tmp30)
+	_tmp31, err := dec.Int64()
+	if err != nil {
+		return err
+	}
+	obj.Delta = _tmp31
+	_tmp32, err := dec.Int64()
+	if err != nil {
+		return err
+	}
+	obj.Neg = int(_tmp32)
+	_tmp33, err := dec.Float64()
+	if err != nil {
+		return err
+	}
+	obj.Ratio = _tmp33
+	_tmp34, err := dec.Bytes()
+	if err != nil {
+		return err
+	}
+	obj.Name = string(_tmp34)
+	_tmp35, err := dec.Bytes()
+	if err != nil {
+		return err
+	}
+	obj.Label = Label(_tmp35)
+	_tmp36, err := dec.Bytes()
+	if err != nil {
+		return err
+	}
+	obj.Data = _tmp36
+	if err := dec.ReadBytes(obj.Hash[:]); err != nil {
+		return err
+	}
+	_tmp37, err := dec.BigInt()
+	if err != nil {
+		return err
+	}
+	obj.Amount = _tmp37
+	_tmp38, err := dec.BigInt()
+	if err != nil {
+		return err
+	}
+	obj.Total.Set(_tmp38)
+	_tmp39, err := dec.Time()
+	if err != nil {
+		return err
+	}
+	obj.Created = _tmp39
+	_tmp40, err := dec.Time()
+	if err != nil {
+		return err
+	}
+	if obj.Updated == nil {
+		obj.Updated = new(time.Time)
+	}
+	*obj.Updated = _tmp40
+	_tmp41, err := dec.Raw()
+	if err != nil {
+		return err
+	}
+	obj.Raw = _tmp41
+	if _, err := dec.List(); err != nil {
+		return err
+	}
+	_tmp42 := []Item{}
+	for dec.MoreDataInList() {
+		var _elem43 Item
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		_tmp44, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		_elem43.ID = _tmp44
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		_tmp45 := []string{}
+		for dec.MoreDataInList() {
+			var _elem46 string
+			_tmp47, err := dec.Bytes()
+			if err != nil {
+				return err
+			}
+			_elem46 = string(_tmp47)
+			_tmp45 = append(_tmp45, _elem46)
+		}
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+		_elem43.Tags = _tmp45
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		_tmp48, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		_elem43.Sub.A = uint(_tmp48)
+		_tmp49, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		_elem43.Sub.B = uint(_tmp49)
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+		_tmp42 = append(_tmp42, _elem43)
+	}
+	if err := dec.ListEnd(); err != nil {
+		return err
+	}
+	obj.Items = _tmp42
+	if _, err := dec.List(); err != nil {
+		return err
+	}
+	for _i50 := range obj.Matrix {
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		_tmp51 := []uint16{}
+		for dec.MoreDataInList() {
+			var _elem52 uint16
+			_tmp53, err := dec.Uint16()
+			if err != nil {
+				return err
+			}
+			_elem52 = _tmp53
+			_tmp51 = append(_tmp51, _elem52)
+		}
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+		obj.Matrix[_i50] = _tmp51
+	}
+	if err := dec.ListEnd(); err != nil {
+		return err
+	}
+	if obj.Ptr == nil {
+		obj.Ptr = new(Item)
+	}
+	if _, err := dec.List(); err != nil {
+		return err
+	}
+	_tmp54, err := dec.Uint64()
+	if err != nil {
+		return err
+	}
+	(*obj.Ptr).ID = _tmp54
+	if _, err := dec.List(); err != nil {
+		return err
+	}
+	_tmp55 := []string{}
+	for dec.MoreDataInList() {
+		var _elem56 string
+		_tmp57, err := dec.Bytes()
+		if err != nil {
+			return err
+		}
+		_elem56 = string(_tmp57)
+		_tmp55 = append(_tmp55, _elem56)
+	}
+	if err := dec.ListEnd(); err != nil {
+		return err
+	}
+	(*obj.Ptr).Tags = _tmp55
+	if _, err := dec.List(); err != nil {
+		return err
+	}
+	_tmp58, err := dec.Uint64()
+	if err != nil {
+		return err
+	}
+	(*obj.Ptr).Sub.A = uint(_tmp58)
+	_tmp59, err := dec.Uint64()
+	if err != nil {
+		return err
+	}
+	(*obj.Ptr).Sub.B = uint(_tmp59)
+	if err := dec.ListEnd(); err != nil {
+		return err
+	}
+	if err := dec.ListEnd(); err != nil {
+		return err
+	}
+	_kind60, _size61, err := dec.Kind()
+	if err != nil {
+		return err
+	}
+	if _kind60 != rlp.Byte && _size61 == 0 {
+		if _kind60 != rlp.List {
+			return rlp.ErrExpectedList
+		}
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+		obj.NilItem = nil
+	} else {
+		if obj.NilItem == nil {
+			obj.NilItem = new(Item)
+		}
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		_tmp62, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		(*obj.NilItem).ID = _tmp62
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		_tmp63 := []string{}
+		for dec.MoreDataInList() {
+			var _elem64 string
+			_tmp65, err := dec.Bytes()
+			if err != nil {
+				return err
+			}
+			_elem64 = string(_tmp65)
+			_tmp63 = append(_tmp63, _elem64)
+		}
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+		(*obj.NilItem).Tags = _tmp63
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		_tmp66, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		(*obj.NilItem).Sub.A = uint(_tmp66)
+		_tmp67, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		(*obj.NilItem).Sub.B = uint(_tmp67)
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+	}
+	_kind68, _size69, err := dec.Kind()
+	if err != nil {
+		return err
+	}
+	if _kind68 != rlp.Byte && _size69 == 0 {
+		if _kind68 != rlp.String {
+			return rlp.ErrExpectedString
+		}
+		if _, err := dec.Bytes(); err != nil {
+			return err
+		}
+		obj.NilStr = nil
+	} else {
+		if obj.NilStr == nil {
+			obj.NilStr = new([]uint)
+		}
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		_tmp70 := []uint{}
+		for dec.MoreDataInList() {
+			var _elem71 uint
+			_tmp72, err := dec.Uint64()
+			if err != nil {
+				return err
+			}
+			_elem71 = uint(_tmp72)
+			_tmp70 = append(_tmp70, _elem71)
+		}
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+		(*obj.NilStr) = _tmp70
+	}
+	_kind73, _size74, err := dec.Kind()
+	if err != nil {
+		return err
+	}
+	if _kind73 != rlp.Byte && _size74 == 0 {
+		if _kind73 != rlp.List {
+			return rlp.ErrExpectedList
+		}
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+		obj.NilList = nil
+	} else {
+		if obj.NilList == nil {
+			obj.NilList = new(uint64)
+		}
+		_tmp75, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		(*obj.NilList) = _tmp75
+	}
+	if err := dec.Decode(&obj.Attrs); err != nil {
+		return err
+	}
+	if err := dec.Decode(&obj.Any); err != nil {
+		return err
+	}
+	if err := obj.Custom.DecodeRLP(dec); err != nil {
+		return err
+	}
+	if _, err := dec.List(); err != nil {
+		return err
+	}
+	if _tmp76, err := dec.Uint64(); err != nil {
+		return err
+	} else if _tmp76 != uint64(obj.Doc.RLPVersion()) {
+		if err := rlp.Migrate(dec, uint(_tmp76), &obj.Doc); err != nil {
+			return err
+		}
+	} else {
+		_tmp77, err := dec.Bytes()
+		if err != nil {
+			return err
+		}
+		obj.Doc.Title = string(_tmp77)
+		_tmp78, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		obj.Doc.Pages = uint(_tmp78)
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+	}
+	if err := dec.ReadBigEndian(&obj.Wide); err != nil {
+		return err
+	}
+	if obj.WidePtr == nil {
+		obj.WidePtr = new(rlp.Uint256)
+	}
+	if err := dec.ReadBigEndian(obj.WidePtr); err != nil {
+		return err
+	}
+	_tmp79, err := dec.Bytes()
+	if err != nil {
+		return err
+	}
+	obj.Meta.Owner = string(_tmp79)
+	_tmp80, err := dec.Uint64()
+	if err != nil {
+		return err
+	}
+	obj.Meta.Rev = uint(_tmp80)
+	if dec.MoreDataInList() {
+		_tmp81, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		obj.Version = _tmp81
+		if dec.MoreDataInList() {
+			_tmp82, err := dec.Float64()
+			if err != nil {
+				return err
+			}
+			obj.Level = _tmp82
+			if dec.MoreDataInList() {
+				_tmp83, err := dec.Bytes()
+				if err != nil {
+					return err
+				}
+				obj.Tag = Label(_tmp83)
+				_tmp84 := []string{}
+				for dec.MoreDataInList() {
+					var _elem85 string
+					_tmp86, err := dec.Bytes()
+					if err != nil {
+						return err
+					}
+					_elem85 = string(_tmp86)
+					_tmp84 = append(_tmp84, _elem85)
+				}
+				obj.Rest = _tmp84
+			} else {
+				obj.Tag = ""
+				obj.Rest = nil
+			}
+		} else {
+			obj.Level = 0
+			obj.Tag = ""
+			obj.Rest = nil
+		}
+	} else {
+		obj.Version = 0
+		obj.Level = 0
+		obj.Tag = ""
+		obj.Rest = nil
+	}
+	if err := dec.ListEnd(); err != nil {
+		return err
+	}
+	return nil
+}
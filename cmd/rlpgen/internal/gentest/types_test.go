@@ -0,0 +1,163 @@
+package gentest
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Yamiyo/common/rlp"
+)
+
+// plainRecord has the same layout as Record but no methods, so package
+// rlp encodes and decodes it by reflection.
+type plainRecord Record
+
+func testRecords() []Record {
+	updated := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	list := uint64(7)
+	// Raw, Ptr and Updated are always set because an empty RawValue
+	// isn't valid RLP, a nil *Item encodes as an empty list, which can't
+	// be decoded back into an Item, and a nil *time.Time encodes as the
+	// empty string, which isn't a valid time.
+	return []Record{
+		{Raw: rlp.RawValue(rlp.EmptyString), Ptr: new(Item), Updated: &updated},
+		{
+			Flag:    true,
+			Small:   0x7F,
+			Count:   0x80,
+			Size:    1 << 40,
+			Delta:   -300,
+			Neg:     -1,
+			Ratio:   -0.1,
+			Name:    "a",
+			Label:   "label",
+			Data:    []byte{0x00},
+			Hash:    [4]byte{1, 2, 3, 4},
+			Amount:  big.NewInt(0xFFFFFF),
+			Total:   *big.NewInt(1),
+			Created: time.Date(2021, 12, 31, 23, 59, 59, 0, time.FixedZone("", 8*3600)),
+			Updated: &updated,
+			Raw:     rlp.RawValue{0xC2, 0x01, 0x02},
+			Items: []Item{
+				{ID: 1, Tags: []string{"x", "y"}},
+				{ID: 2, Sub: struct{ A, B uint }{3, 4}},
+			},
+			Matrix:  [2][]uint16{{1, 2}, {0xFFFF}},
+			Ptr:     &Item{ID: 5},
+			NilItem: &Item{ID: 6, Tags: []string{}},
+			NilStr:  &[]uint{9},
+			NilList: &list,
+			Attrs:   map[string]uint{"b": 2, "a": 1},
+			Any:     []interface{}{[]byte("any")},
+			Custom:  Custom{V: 8},
+			Doc:     Doc{Title: "doc", Pages: 3},
+			Wide:    rlp.Uint256{0, 0, 0, 1 << 63},
+			WidePtr: &rlp.Uint256{0x80},
+			Meta:    Meta{Owner: "me", Rev: 4},
+			Version: 2,
+			Level:   1.5,
+			Tag:     "opt",
+			Rest:    []string{"r1", "r2"},
+		},
+		{
+			Raw:     rlp.RawValue(rlp.EmptyList),
+			Ptr:     new(Item),
+			Updated: &updated,
+			Data:    bytes.Repeat([]byte{0xAB}, 100),
+			Name:    string(bytes.Repeat([]byte{'n'}, 56)),
+			Items:   make([]Item, 20),
+			Attrs:   map[string]uint{},
+		},
+		// Only the first optional field is left out.
+		{Raw: rlp.RawValue(rlp.EmptyString), Ptr: new(Item), Updated: &updated, Tag: "t"},
+		{Raw: rlp.RawValue(rlp.EmptyString), Ptr: new(Item), Updated: &updated, Version: 1, Rest: []string{}},
+	}
+}
+
+func TestGeneratedEncodingMatchesReflection(t *testing.T) {
+	for i, rec := range testRecords() {
+		rec := rec
+		gen, err := rlp.EncodeToBytes(&rec)
+		if err != nil {
+			t.Fatalf("test %d: generated encoder error: %v", i, err)
+		}
+		ref, err := rlp.EncodeToBytes((*plainRecord)(&rec))
+		if err != nil {
+			t.Fatalf("test %d: reflective encoder error: %v", i, err)
+		}
+		if !bytes.Equal(gen, ref) {
+			t.Errorf("test %d: output mismatch\ngenerated  %x\nreflective %x", i, gen, ref)
+		}
+
+		var genDec Record
+		if err := rlp.DecodeBytes(ref, &genDec); err != nil {
+			t.Fatalf("test %d: generated decoder error: %v", i, err)
+		}
+		var refDec plainRecord
+		if err := rlp.DecodeBytes(ref, &refDec); err != nil {
+			t.Fatalf("test %d: reflective decoder error: %v", i, err)
+		}
+		if !reflect.DeepEqual(genDec, Record(refDec)) {
+			t.Errorf("test %d: decoded value mismatch\ngenerated  %#v\nreflective %#v", i, genDec, refDec)
+		}
+	}
+}
+
+func TestGeneratedDecoderMigratesOldVersion(t *testing.T) {
+	valid, err := rlp.EncodeToBytes(&testRecords()[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Replace Doc with its version 1 encoding.
+	var elems []rlp.RawValue
+	if err := rlp.DecodeBytes(valid, &elems); err != nil {
+		t.Fatal(err)
+	}
+	docField, _ := reflect.TypeOf(Record{}).FieldByName("Doc")
+	elems[docField.Index[0]], _ = rlp.EncodeToBytes([]interface{}{uint(1), "old"})
+	input, _ := rlp.EncodeToBytes(elems)
+
+	var genDec Record
+	if err := rlp.DecodeBytes(input, &genDec); err != nil {
+		t.Fatalf("generated decoder error: %v", err)
+	}
+	var refDec plainRecord
+	if err := rlp.DecodeBytes(input, &refDec); err != nil {
+		t.Fatalf("reflective decoder error: %v", err)
+	}
+	if want := (Doc{Title: "old", Pages: 1}); genDec.Doc != want {
+		t.Errorf("wrong migrated value %+v, want %+v", genDec.Doc, want)
+	}
+	if !reflect.DeepEqual(genDec, Record(refDec)) {
+		t.Errorf("decoded value mismatch\ngenerated  %#v\nreflective %#v", genDec, refDec)
+	}
+}
+
+func TestGeneratedDecoderRejectsInvalidInput(t *testing.T) {
+	valid, err := rlp.EncodeToBytes(&testRecords()[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := [][]byte{
+		{},
+		{0xC0},
+		valid[:len(valid)-1],
+		append(append([]byte{}, valid...), 0x01),
+	}
+	// Replace the Flag value with a non-canonical integer.
+	nonCanon := append([]byte{}, valid...)
+	nonCanon[3] = 0x00
+	inputs = append(inputs, nonCanon)
+
+	for i, input := range inputs {
+		var genDec Record
+		genErr := rlp.DecodeBytes(input, &genDec)
+		var refDec plainRecord
+		refErr := rlp.DecodeBytes(input, &refDec)
+		if genErr == nil || refErr == nil {
+			t.Errorf("input %d: expected errors, got generated %v, reflective %v", i, genErr, refErr)
+		}
+	}
+}
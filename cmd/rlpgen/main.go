@@ -0,0 +1,143 @@
+// Command rlpgen generates reflection-free EncodeRLP and DecodeRLP methods
+// for a struct type.
+//
+// Usage:
+//
+//	rlpgen -type Record [-dir .] [-out record_rlp.go]
+//
+// The generated methods follow the encoding rules of package rlp, including
+// the "-", "tail", "optional", "nil", "nilString" and "nilList" struct tags
+// and the version number of rlp.Versioned types, and produce the same bytes
+// as the reflection-based encoder. Old versions are decoded by rlp.Migrate.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	var (
+		dir     = flag.String("dir", ".", "package directory")
+		typName = flag.String("type", "", "type to generate methods for")
+		output  = flag.String("out", "", "output file (default stdout)")
+	)
+	flag.Parse()
+
+	if *typName == "" {
+		fatal("-type is required")
+	}
+	code, err := generate(*dir, *typName, *output)
+	if err != nil {
+		fatal(err)
+	}
+	if *output == "" {
+		os.Stdout.Write(code)
+		return
+	}
+	if err := ioutil.WriteFile(*output, code, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(args ...interface{}) {
+	fmt.Fprintln(os.Stderr, append([]interface{}{"rlpgen:"}, args...)...)
+	os.Exit(1)
+}
+
+// generate loads the package in dir and returns the generated code for the
+// named type. The output file is excluded from loading so that stale
+// generated code doesn't affect the result.
+func generate(dir, typName, output string) ([]byte, error) {
+	pkg, err := loadPackage(dir, output)
+	if err != nil {
+		return nil, err
+	}
+	obj := pkg.Scope().Lookup(typName)
+	if obj == nil {
+		return nil, fmt.Errorf("type %s not found in package %s", typName, pkg.Path())
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a named type", typName)
+	}
+	ctx := newBuildContext(pkg, named)
+	return ctx.generate()
+}
+
+func loadPackage(dir, output string) (*types.Package, error) {
+	var skip string
+	if output != "" {
+		skip, _ = filepath.Abs(output)
+	}
+	fset := token.NewFileSet()
+	filter := func(fi os.FileInfo) bool {
+		if strings.HasSuffix(fi.Name(), "_test.go") {
+			return false
+		}
+		abs, _ := filepath.Abs(filepath.Join(dir, fi.Name()))
+		return abs != skip
+	}
+	pkgs, err := parser.ParseDir(fset, dir, filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+	var files []*ast.File
+	for _, p := range pkgs {
+		for _, f := range p.Files {
+			files = append(files, f)
+		}
+	}
+	path, err := importPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	return conf.Check(path, fset, files, nil)
+}
+
+// importPath determines the import path of the package in dir by finding
+// the enclosing go.mod file.
+func importPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		data, err := ioutil.ReadFile(filepath.Join(d, "go.mod"))
+		if err == nil {
+			mod := modulePath(data)
+			if mod == "" {
+				return "", fmt.Errorf("no module directive in %s", filepath.Join(d, "go.mod"))
+			}
+			rel, _ := filepath.Rel(d, abs)
+			return filepath.ToSlash(filepath.Join(mod, rel)), nil
+		}
+		if filepath.Dir(d) == d {
+			return "", fmt.Errorf("no go.mod found for %s", dir)
+		}
+	}
+}
+
+func modulePath(gomod []byte) string {
+	for _, line := range bytes.Split(gomod, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if bytes.HasPrefix(line, []byte("module ")) {
+			return strings.Trim(string(bytes.TrimSpace(line[len("module "):])), `"`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+// Package invalid contains types that rlpgen must reject.
+package invalid
+
+type Recursive struct {
+	A    uint
+	Next *Node
+}
+
+type Node struct {
+	Child *Node
+}
+
+type NamedByte byte
+
+type NamedBytes struct {
+	B []NamedByte
+}
+
+type BadTail struct {
+	A []uint `rlp:"tail"`
+	B uint
+}
+
+type BadNil struct {
+	A uint `rlp:"nil"`
+}
+
+type Unsupported struct {
+	C chan int
+}
+
+type UnknownTag struct {
+	A uint `rlp:"foo"`
+}
+
+type BadOptional struct {
+	A uint `rlp:"optional"`
+	B uint
+}
+
+type OptionalTail struct {
+	A []uint `rlp:"optional,tail"`
+}
+
+type UnsupportedOptional struct {
+	A uint
+	B []uint             `rlp:"optional"`
+	C struct{ D []uint } `rlp:"optional"`
+}
+
+type BadInline struct {
+	A uint `rlp:"inline"`
+}
+
+type Tail struct {
+	T []uint `rlp:"tail"`
+}
+
+type InlineTail struct {
+	Tail `rlp:"inline"`
+	B    uint
+}
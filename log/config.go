@@ -0,0 +1,82 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config configures a Logger. The service identity is written to every
+// log line and sent with Slack alerts.
+type Config struct {
+	ServiceName string
+	Version     string
+	ServiceCode string
+	InstanceID  string // defaults to the host name
+
+	Env           string // "dev" logs text to stdout and the log files, others JSON
+	Level         string // minimum level, defaults to "info"
+	Path          string // directory of the log files, none are written if empty
+	Duration      string // time covered by each log file, e.g. "24h", unlimited if empty
+	SlackURL      string // Slack webhook, alerts are disabled if empty
+	SlackChannel  string
+	SlackLevel    string // minimum level sent to Slack, defaults to "error"
+	ForceColor    bool
+	FullTimestamp bool
+
+	// Rotation and retention of the log files in Path. Rotated files are
+	// deleted once they are older than MaxAge or more than MaxFiles newer
+	// ones exist. Path/current.log links to the file being written.
+	// Only one Logger can use Path at a time: New fails while another
+	// Logger, in this or another process, has it open. This isn't
+	// enforced on systems without flock, such as Windows.
+	MaxSize  int64         // size in bytes at which a new file is started, unlimited if zero
+	MaxAge   time.Duration // kept forever if zero
+	MaxFiles int           // all kept if zero
+	Compress bool          // gzip rotated files
+
+	// Output receives the log lines in addition to the log files. If it
+	// is nil, os.Stdout is used when Path is empty or Env is "dev".
+	Output io.Writer
+
+	// Formatter overrides the format chosen by Env.
+	Formatter logrus.Formatter
+}
+
+// InitWithConfig replaces the default logger with one created by New and
+// closes the previous one. It panics if cfg is invalid.
+func InitWithConfig(cfg Config) {
+	if err := replaceDefault(cfg); err != nil {
+		panic(fmt.Sprintf("InitWithConfig %v", err))
+	}
+}
+
+// replaceDefault replaces the default logger with one created by New and
+// closes the previous one. If both use the same log directory, the
+// previous logger is closed first because only one logger can use a
+// directory at a time.
+func replaceDefault(cfg Config) error {
+	if prev := Default(); prev.file != nil && cfg.Path != "" &&
+		filepath.Clean(prev.file.dir) == filepath.Clean(cfg.Path) {
+		prev.Close()
+	}
+	l, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	SetDefault(l).Close()
+	return nil
+}
+
+// identityFields returns the service identity of cfg as entry fields.
+func identityFields(cfg Config) logrus.Fields {
+	return logrus.Fields{
+		serviceKey:     cfg.ServiceName,
+		versionKey:     cfg.Version,
+		serviceCodeKey: cfg.ServiceCode,
+		instanceIDKey:  cfg.InstanceID,
+	}
+}
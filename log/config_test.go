@@ -0,0 +1,81 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConfigIdentity(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Skip("no host name:", err)
+	}
+	var buf bytes.Buffer
+	l, err := New(Config{ServiceName: "svc", Version: "v2", ServiceCode: "7", Output: &buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info(context.Background(), "first")
+	l.WithFields(context.Background(), Fields{"n": 1}).Warn("second")
+
+	// Every line carries the identity, the instance ID defaults to the
+	// host name.
+	msgs := decodeLines(t, buf.Bytes())
+	if len(msgs) != 2 {
+		t.Fatalf("got %d lines, want 2", len(msgs))
+	}
+	for _, m := range msgs {
+		if m.Service != "svc" || m.Version != "v2" || m.ServiceCode != "7" || m.InstanceID != host {
+			t.Errorf("wrong identity in %+v", m)
+		}
+	}
+}
+
+func TestSlackIdentity(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	l, err := New(Config{
+		ServiceName: "svc",
+		Version:     "v2",
+		ServiceCode: "7",
+		InstanceID:  "i1",
+		SlackURL:    srv.URL,
+		Output:      ioutil.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithChainID(context.Background(), "chain")
+	l.WithFields(ctx, Fields{"n": 1}).Error("alert")
+
+	// The alert lists all fields, with the identity under readable names.
+	var msg struct {
+		Attachments []struct {
+			Fields []struct{ Value string }
+		}
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		t.Fatalf("invalid Slack message %q: %v", body, err)
+	}
+	if len(msg.Attachments) != 1 || len(msg.Attachments[0].Fields) != 1 {
+		t.Fatalf("unexpected Slack message %s", body)
+	}
+	want := "version: `v2`, serviceCode: `7`, service: `svc`, n: `1`, instanceID: `i1`, chainID: `chain`"
+	if got := msg.Attachments[0].Fields[0].Value; got != want {
+		t.Errorf("wrong fields in Slack message\ngot  %s\nwant %s", got, want)
+	}
+	if strings.Contains(string(body), "log.") {
+		t.Errorf("Slack message contains namespaced keys: %s", body)
+	}
+}
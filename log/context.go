@@ -0,0 +1,119 @@
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// contextKey is the type of the context keys of this package, so they
+// can't collide with keys of other packages.
+type contextKey int
+
+const (
+	chainIDContextKey contextKey = iota
+	traceContextKey
+)
+
+// legacyChainIDKey is the string key under which the chain ID was stored
+// before WithChainID existed. ChainIDFrom still reads it.
+const legacyChainIDKey = "ChainID"
+
+// WithChainID returns a copy of ctx carrying the chain ID id. Log lines
+// written with the returned context include it.
+func WithChainID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, chainIDContextKey, id)
+}
+
+// ChainIDFrom returns the chain ID of ctx, or "" if it has none.
+func ChainIDFrom(ctx context.Context) string {
+	if id, ok := ctx.Value(chainIDContextKey).(string); ok {
+		return id
+	}
+	id, _ := ctx.Value(legacyChainIDKey).(string)
+	return id
+}
+
+// TraceContext identifies the current span of a distributed trace, as
+// carried by the W3C traceparent header.
+type TraceContext struct {
+	TraceID string // 32 lowercase hex digits
+	SpanID  string // 16 lowercase hex digits
+	Sampled bool
+}
+
+var errInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses a traceparent header value such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceparent(s string) (TraceContext, error) {
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return TraceContext{}, errInvalidTraceparent
+	}
+	version, traceID, spanID, flags := s[:2], s[3:35], s[36:52], s[53:55]
+	if !isLowerHex(version) || version == "ff" || (version == "00" && len(s) != 55) || (len(s) > 55 && s[55] != '-') {
+		return TraceContext{}, errInvalidTraceparent
+	}
+	if !isLowerHex(traceID) || isZeroID(traceID) || !isLowerHex(spanID) || isZeroID(spanID) || !isLowerHex(flags) {
+		return TraceContext{}, errInvalidTraceparent
+	}
+	b, _ := hex.DecodeString(flags)
+	return TraceContext{TraceID: traceID, SpanID: spanID, Sampled: b[0]&1 == 1}, nil
+}
+
+// NewTraceContext starts a new trace with random IDs.
+func NewTraceContext() TraceContext {
+	return TraceContext{TraceID: randomID(16), SpanID: randomID(8)}
+}
+
+// NewSpan returns a new span in the same trace.
+func (t TraceContext) NewSpan() TraceContext {
+	t.SpanID = randomID(8)
+	return t
+}
+
+// Traceparent returns t as a traceparent header value.
+func (t TraceContext) Traceparent() string {
+	flags := "00"
+	if t.Sampled {
+		flags = "01"
+	}
+	return "00-" + t.TraceID + "-" + t.SpanID + "-" + flags
+}
+
+// WithTrace returns a copy of ctx carrying t. Log lines written with the
+// returned context include its trace and span IDs.
+func WithTrace(ctx context.Context, t TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey, t)
+}
+
+// TraceFrom returns the trace context of ctx.
+func TraceFrom(ctx context.Context) (TraceContext, bool) {
+	t, ok := ctx.Value(traceContextKey).(TraceContext)
+	return t, ok
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZeroID(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+// randomID returns n random bytes in hex.
+func randomID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("log: can't read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
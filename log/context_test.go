@@ -0,0 +1,92 @@
+package log
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	tests := []struct {
+		input string
+		want  TraceContext
+		err   bool
+	}{
+		{input: "00-" + traceID + "-" + spanID + "-01", want: TraceContext{TraceID: traceID, SpanID: spanID, Sampled: true}},
+		{input: "00-" + traceID + "-" + spanID + "-00", want: TraceContext{TraceID: traceID, SpanID: spanID}},
+		{input: "00-" + traceID + "-" + spanID + "-03", want: TraceContext{TraceID: traceID, SpanID: spanID, Sampled: true}},
+
+		// Future versions may append fields after a dash.
+		{input: "01-" + traceID + "-" + spanID + "-01", want: TraceContext{TraceID: traceID, SpanID: spanID, Sampled: true}},
+		{input: "cc-" + traceID + "-" + spanID + "-01-what-the-future-holds", want: TraceContext{TraceID: traceID, SpanID: spanID, Sampled: true}},
+		{input: "cc-" + traceID + "-" + spanID + "-01.what-the-future-holds", err: true},
+
+		// Invalid values.
+		{input: "", err: true},
+		{input: "00-" + traceID + "-" + spanID + "-0", err: true},
+		{input: "00-" + traceID + "-" + spanID, err: true},
+		{input: "ff-" + traceID + "-" + spanID + "-01", err: true},
+		{input: "00-" + traceID + "-" + spanID + "-01-extra", err: true},
+		{input: "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + spanID + "-01", err: true},
+		{input: "00-" + traceID + "-00F067AA0BA902B7-01", err: true},
+		{input: "0A-" + traceID + "-" + spanID + "-01", err: true},
+		{input: "00-00000000000000000000000000000000-" + spanID + "-01", err: true},
+		{input: "00-" + traceID + "-0000000000000000-01", err: true},
+		{input: "00_" + traceID + "_" + spanID + "_01", err: true},
+		{input: "00-" + traceID + "-" + spanID + "-zz", err: true},
+	}
+	for _, test := range tests {
+		got, err := ParseTraceparent(test.input)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: no error, got %+v", test.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.input, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: got %+v, want %+v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	for _, sampled := range []bool{false, true} {
+		tc := NewTraceContext()
+		tc.Sampled = sampled
+		got, err := ParseTraceparent(tc.Traceparent())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc {
+			t.Errorf("got %+v, want %+v", got, tc)
+		}
+	}
+}
+
+func TestChainIDFrom(t *testing.T) {
+	ctx := context.Background()
+	if id := ChainIDFrom(ctx); id != "" {
+		t.Errorf("got %q for empty context", id)
+	}
+
+	// The string key used by earlier versions is still read.
+	legacy := context.WithValue(ctx, legacyChainIDKey, "legacy")
+	if id := ChainIDFrom(legacy); id != "legacy" {
+		t.Errorf("got %q for legacy key, want %q", id, "legacy")
+	}
+	if id := ChainIDFrom(context.WithValue(ctx, legacyChainIDKey, 5)); id != "" {
+		t.Errorf("got %q for non-string legacy value", id)
+	}
+
+	// WithChainID takes precedence over the legacy key.
+	if id := ChainIDFrom(WithChainID(legacy, "new")); id != "new" {
+		t.Errorf("got %q, want %q", id, "new")
+	}
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Entry is a log line under construction. It carries structured fields,
+// which JSONFormatter writes as separate keys instead of formatting them
+// into the message.
+type Entry struct {
+	entry *logrus.Entry
+}
+
+// WithFields returns an entry of the default logger with the given fields
+// and the chain ID and trace context of ctx, for example
+//
+//	log.WithFields(ctx, log.Fields{"user": id, "amount": amount}).Info("deposit")
+func WithFields(ctx context.Context, fields Fields) *Entry {
+	return Default().WithFields(ctx, fields)
+}
+
+// WithFields returns an entry of l with the given fields and the chain ID
+// and trace context of ctx.
+func (l *Logger) WithFields(ctx context.Context, fields Fields) *Entry {
+	return l.entry(ctx).WithFields(fields)
+}
+
+// entry returns an entry with the fields every log line has.
+func (l *Logger) entry(ctx context.Context) *Entry {
+	fields := make(logrus.Fields, len(l.identity)+3)
+	for k, v := range l.identity {
+		fields[k] = v
+	}
+	fields[chainIDKey] = ChainIDFrom(ctx)
+	if trace, ok := TraceFrom(ctx); ok {
+		fields[traceIDKey] = trace.TraceID
+		fields[spanIDKey] = trace.SpanID
+	}
+	return &Entry{entry: l.logger.WithContext(ctx).WithFields(fields)}
+}
+
+// WithFields returns a copy of e with fields added.
+func (e *Entry) WithFields(fields Fields) *Entry {
+	return &Entry{entry: e.entry.WithFields(logrus.Fields(fields))}
+}
+
+// Debug logs a message at level Debug.
+func (e *Entry) Debug(args ...interface{}) {
+	e.entry.Debug(args...)
+}
+
+// Debugf logs a message at level Debug.
+func (e *Entry) Debugf(msg string, args ...interface{}) {
+	e.entry.Debugf(msg, args...)
+}
+
+// Info logs a message at level Info.
+func (e *Entry) Info(args ...interface{}) {
+	e.entry.Info(args...)
+}
+
+// Infof logs a message at level Info.
+func (e *Entry) Infof(msg string, args ...interface{}) {
+	e.entry.Infof(msg, args...)
+}
+
+// Warn logs a message at level Warn.
+func (e *Entry) Warn(args ...interface{}) {
+	e.entry.Warn(args...)
+}
+
+// Warnf logs a message at level Warn.
+func (e *Entry) Warnf(msg string, args ...interface{}) {
+	e.entry.Warnf(msg, args...)
+}
+
+// Error logs a message at level Error.
+func (e *Entry) Error(args ...interface{}) {
+	e.entry.Error(args...)
+}
+
+// Errorf logs a message at level Error.
+func (e *Entry) Errorf(msg string, args ...interface{}) {
+	e.entry.Errorf(msg, args...)
+}
+
+// Panic logs a message at level Panic, then panics.
+func (e *Entry) Panic(args ...interface{}) {
+	e.entry.Panic(args...)
+}
+
+// Panicf logs a message at level Panic, then panics.
+func (e *Entry) Panicf(msg string, args ...interface{}) {
+	e.entry.Panicf(msg, args...)
+}
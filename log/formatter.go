@@ -0,0 +1,79 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Keys of the entry fields that JSONFormatter moves to the top level of a
+// Message. They are namespaced so that fields passed to WithFields can't
+// replace them: a user field named "chainID" is kept under "fields".
+const (
+	chainIDKey     = "log.chainID"
+	serviceKey     = "log.service"
+	versionKey     = "log.version"
+	serviceCodeKey = "log.serviceCode"
+	instanceIDKey  = "log.instanceID"
+	traceIDKey     = "log.traceID"
+	spanIDKey      = "log.spanID"
+)
+
+// levelNames are the level names written to Message.Level.
+var levelNames = map[logrus.Level]string{
+	logrus.TraceLevel: "Trace",
+	logrus.DebugLevel: "Debug",
+	logrus.InfoLevel:  "Info",
+	logrus.WarnLevel:  "Warn",
+	logrus.ErrorLevel: "Error",
+	logrus.FatalLevel: "Fatal",
+	logrus.PanicLevel: "Panic",
+}
+
+// JSONFormatter writes each entry as one Message in JSON. The chain ID,
+// trace IDs and service identity become top-level keys, all other fields are
+// kept under "fields".
+type JSONFormatter struct{}
+
+// Format implements logrus.Formatter.
+func (f *JSONFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	msg := Message{
+		Level: levelNames[e.Level],
+		Time:  e.Time.UTC().Format(time.RFC3339),
+		Msg:   e.Message,
+	}
+	for k, v := range e.Data {
+		switch k {
+		case chainIDKey:
+			msg.ChainID = fmt.Sprint(v)
+		case serviceKey:
+			msg.Service = fmt.Sprint(v)
+		case instanceIDKey:
+			msg.InstanceID = fmt.Sprint(v)
+		case traceIDKey:
+			msg.TraceID = fmt.Sprint(v)
+		case spanIDKey:
+			msg.SpanID = fmt.Sprint(v)
+		case versionKey:
+			msg.Version = fmt.Sprint(v)
+		case serviceCodeKey:
+			msg.ServiceCode = fmt.Sprint(v)
+		default:
+			if msg.Fields == nil {
+				msg.Fields = make(Fields, len(e.Data))
+			}
+			// Errors would marshal as empty objects.
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			msg.Fields[k] = v
+		}
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal log message: %v", err)
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,75 @@
+package log
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestJSONFormatter(t *testing.T) {
+	tests := []struct {
+		entry *logrus.Entry
+		want  string
+	}{
+		{
+			entry: &logrus.Entry{
+				Level:   logrus.InfoLevel,
+				Time:    time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("", 3600)),
+				Message: "hello",
+			},
+			want: `{"chainID":"","level":"Info","version":"","serviceCode":"","time":"2021-03-04T04:06:07Z","msg":"hello"}`,
+		},
+		{
+			entry: &logrus.Entry{
+				Level:   logrus.WarnLevel,
+				Time:    time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+				Message: "with identity",
+				Data: logrus.Fields{
+					chainIDKey:     "chain",
+					traceIDKey:     "4bf92f3577b34da6a3ce929d0e0e4736",
+					spanIDKey:      "00f067aa0ba902b7",
+					serviceKey:     "svc",
+					versionKey:     "v2",
+					serviceCodeKey: "7",
+					instanceIDKey:  "i1",
+				},
+			},
+			want: `{"chainID":"chain","traceID":"4bf92f3577b34da6a3ce929d0e0e4736","spanID":"00f067aa0ba902b7","level":"Warn","service":"svc","version":"v2","serviceCode":"7","instanceID":"i1","time":"2021-03-04T05:06:07Z","msg":"with identity"}`,
+		},
+		{
+			// Other fields are kept under "fields", errors as their message.
+			entry: &logrus.Entry{
+				Level:   logrus.ErrorLevel,
+				Time:    time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+				Message: "with fields",
+				Data: logrus.Fields{
+					chainIDKey: "chain",
+					"n":        1,
+					"err":      errors.New("failed"),
+					"service":  "user",
+				},
+			},
+			want: `{"chainID":"chain","level":"Error","version":"","serviceCode":"","time":"2021-03-04T05:06:07Z","msg":"with fields","fields":{"err":"failed","n":1,"service":"user"}}`,
+		},
+	}
+	f := new(JSONFormatter)
+	for i, test := range tests {
+		out, err := f.Format(test.entry)
+		if err != nil {
+			t.Errorf("test %d: error: %v", i, err)
+			continue
+		}
+		if got := string(out); got != test.want+"\n" {
+			t.Errorf("test %d: wrong output\ngot  %s\nwant %s", i, got, test.want)
+		}
+	}
+}
+
+func TestJSONFormatterMarshalError(t *testing.T) {
+	e := &logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{"ch": make(chan int)}}
+	if _, err := new(JSONFormatter).Format(e); err == nil {
+		t.Error("no error for unmarshalable field")
+	}
+}
@@ -2,16 +2,14 @@ package log
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
-	"path/filepath"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Yamiyo/common/slack"
-	"github.com/Yamiyo/common/timeutils"
 
 	"github.com/sirupsen/logrus"
 )
@@ -26,227 +24,334 @@ Debug Level Setting
 - panic
 *************************************************/
 
-const (
-	fileTag = "file"
-	lineTag = "line"
-	funcTag = "func"
-)
+// Logger writes log lines for one service. Loggers are independent of
+// each other and of the logrus standard logger, so components can log to
+// different files.
+type Logger struct {
+	logger   *logrus.Logger
+	identity logrus.Fields
 
-type logConf struct { //執行時期的 log 功能配置
-	showFileInfo bool //是否顯示 file name, func name, line number
-	today        *time.Time
-	logpath      string
-	file         *os.File
+	out  io.Writer     // written to besides the log files, or nil
+	file *rotatingFile // nil if no log files are written
 }
 
-var rtLogConf logConf
-var logExit = make(chan error)
-var wg sync.WaitGroup
+// New creates a logger. If cfg.Path is set, the logger writes to log
+// files in that directory, rotated as configured by cfg, until it is
+// closed.
+func New(cfg Config) (*Logger, error) {
+	level := logrus.InfoLevel
+	if cfg.Level != "" {
+		lv, err := logrus.ParseLevel(cfg.Level)
+		if err != nil {
+			return nil, err
+		}
+		level = lv
+	}
+	format := cfg.Formatter
+	if format == nil {
+		if cfg.Env == "dev" {
+			format = &logrus.TextFormatter{ForceColors: cfg.ForceColor, FullTimestamp: cfg.FullTimestamp}
+		} else {
+			format = &JSONFormatter{}
+		}
+	}
+	if cfg.InstanceID == "" {
+		cfg.InstanceID, _ = os.Hostname()
+	}
 
-// Init ...
-func Init(env, level, logpath, duration, url, channel, hookLevel string, forceColor, fullTimestamp bool) {
-	lv, _ := logrus.ParseLevel(level)
-	hookLv, _ := logrus.ParseLevel(hookLevel)
+	l := &Logger{logger: logrus.New(), identity: identityFields(cfg)}
+	l.logger.SetFormatter(format)
+	l.logger.SetLevel(level)
 
-	format := &logrus.TextFormatter{ForceColors: forceColor, FullTimestamp: fullTimestamp}
+	if cfg.SlackURL != "" {
+		hookLevel := logrus.ErrorLevel
+		if cfg.SlackLevel != "" {
+			lv, err := logrus.ParseLevel(cfg.SlackLevel)
+			if err != nil {
+				return nil, err
+			}
+			hookLevel = lv
+		}
+		username := "footbot"
+		if cfg.ServiceName != "" {
+			username = cfg.ServiceName
+		}
+		l.logger.AddHook(slackHook{&slack.Hook{
+			HookURL:        cfg.SlackURL,
+			AcceptedLevels: slack.LevelThreshold(hookLevel),
+			Channel:        cfg.SlackChannel,
+			IconEmoji:      ":ghost:",
+			Username:       username,
+			Env:            cfg.Env,
+		}})
+	}
 
-	if env == "dev" {
-		InitLog(format, lv, hookLv, env, logpath, duration, url, channel, true, false)
-	} else {
-		InitLog(format, lv, hookLv, env, logpath, duration, url, channel, false, false)
+	l.out = cfg.Output
+	if l.out == nil && (cfg.Path == "" || cfg.Env == "dev") {
+		l.out = os.Stdout
+	}
+	if cfg.Path == "" {
+		l.logger.SetOutput(l.out)
+		return l, nil
 	}
+	if err := l.openLogFile(cfg); err != nil {
+		return nil, err
+	}
+	return l, nil
 }
 
-//InitLog config the log
-func InitLog(format logrus.Formatter, level, hookLevel logrus.Level, env, logpath, duration, url, channel string, multiWriter, showFileInfo bool) {
-	d, err := time.ParseDuration(duration)
-	if err != nil {
-		panic(fmt.Sprintf("InitLog %v", err))
+// slackKeys are the names of the identity and trace fields in Slack
+// alerts, the same as in a Message.
+var slackKeys = map[string]string{
+	chainIDKey:     "chainID",
+	serviceKey:     "service",
+	versionKey:     "version",
+	serviceCodeKey: "serviceCode",
+	instanceIDKey:  "instanceID",
+	traceIDKey:     "traceID",
+	spanIDKey:      "spanID",
+}
+
+// slackHook sends entries to Slack with the identity and trace fields
+// renamed to slackKeys. They replace user fields of the same name.
+type slackHook struct {
+	*slack.Hook
+}
+
+// Fire implements logrus.Hook.
+func (h slackHook) Fire(e *logrus.Entry) error {
+	data := make(logrus.Fields, len(e.Data))
+	for k, v := range e.Data {
+		if _, ok := slackKeys[k]; !ok {
+			data[k] = v
+		}
 	}
+	for k, v := range e.Data {
+		if name, ok := slackKeys[k]; ok {
+			data[name] = v
+		}
+	}
+	renamed := *e
+	renamed.Data = data
+	return h.Hook.Fire(&renamed)
+}
 
-	logrus.SetFormatter(format)
-	logrus.SetLevel(level)
+// defaultLogger holds the *Logger used by the package-level functions.
+var defaultLogger atomic.Value
 
-	logrus.AddHook(&slack.Hook{
-		HookURL:        url,
-		AcceptedLevels: slack.LevelThreshold(hookLevel),
-		Channel:        channel,
-		IconEmoji:      ":ghost:",
-		Username:       "footbot",
-		Env:            env,
+func init() {
+	defaultLogger.Store(&Logger{
+		logger:   logrus.New(),
+		identity: identityFields(Config{Version: "v1.0.0", ServiceCode: "100"}),
 	})
+}
 
-	now := time.Now().UTC()
-	t := now.Truncate(d)
+// Default returns the logger used by the package-level functions.
+func Default() *Logger {
+	return defaultLogger.Load().(*Logger)
+}
 
-	fullpath := logpath + "/" + timeutils.Time2String(&t, "_") + "." + "log"
+// SetDefault makes l the logger used by the package-level functions and
+// returns the previous one.
+func SetDefault(l *Logger) *Logger {
+	prev := Default()
+	defaultLogger.Store(l)
+	return prev
+}
 
-	if err := os.MkdirAll(filepath.Dir(fullpath), 0744); err != nil {
-		Error(context.Background(), "error folder create : ", err)
-		os.Exit(1)
+// Init configures the default logger without a service identity. Log
+// lines report version "v1.0.0" and service code "100".
+//
+// Several behaviours differ from earlier versions: outside "dev", lines
+// are written as JSON and only to the log files, where they used to be
+// text written to stdout until the first rotation; an invalid level or
+// hookLevel panics instead of being ignored; and an empty duration is
+// accepted and disables time-based rotation instead of panicking.
+//
+// Deprecated: use InitWithConfig, which also sets the service identity.
+func Init(env, level, logpath, duration, url, channel, hookLevel string, forceColor, fullTimestamp bool) {
+	InitWithConfig(Config{
+		Version:       "v1.0.0",
+		ServiceCode:   "100",
+		Env:           env,
+		Level:         level,
+		Path:          logpath,
+		Duration:      duration,
+		SlackURL:      url,
+		SlackChannel:  channel,
+		SlackLevel:    hookLevel,
+		ForceColor:    forceColor,
+		FullTimestamp: fullTimestamp,
+	})
+}
+
+// InitLog configures the default logger like InitWithConfig. Lines are
+// written to the log files in logpath, and also to stdout if multiWriter
+// is set.
+//
+// Two behaviours differ from earlier versions: showFileInfo is ignored,
+// and without multiWriter nothing is written to stdout.
+//
+// Deprecated: use InitWithConfig, which also sets the service identity.
+func InitLog(format logrus.Formatter, level, hookLevel logrus.Level, env, logpath, duration, url, channel string, multiWriter, showFileInfo bool) {
+	cfg := Config{
+		Version:      "v1.0.0",
+		ServiceCode:  "100",
+		Env:          env,
+		Level:        level.String(),
+		Path:         logpath,
+		Duration:     duration,
+		SlackURL:     url,
+		SlackChannel: channel,
+		SlackLevel:   hookLevel.String(),
+		Formatter:    format,
+	}
+	if multiWriter {
+		cfg.Output = os.Stdout
 	}
+	if err := replaceDefault(cfg); err != nil {
+		panic(fmt.Sprintf("InitLog %v", err))
+	}
+}
 
-	f, err := os.OpenFile(fullpath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+// Stop closes the default logger.
+func Stop() {
+	Default().Close()
+}
+
+// openLogFile starts writing to rotated log files in cfg.Path.
+func (l *Logger) openLogFile(cfg Config) error {
+	var period time.Duration
+	if cfg.Duration != "" {
+		d, err := time.ParseDuration(cfg.Duration)
+		if err != nil {
+			return err
+		}
+		period = d
+	}
+	f, err := newRotatingFile(cfg.Path, period, cfg.MaxSize, cfg.MaxAge, cfg.MaxFiles, cfg.Compress)
 	if err != nil {
-		Error(context.Background(), "error opening file: ", err)
-		os.Exit(1)
+		return err
+	}
+	l.file = f
+	if l.out != nil {
+		l.logger.SetOutput(io.MultiWriter(f, l.out))
+	} else {
+		l.logger.SetOutput(f)
 	}
+	return nil
+}
 
-	if multiWriter {
-		logrus.SetOutput(io.MultiWriter(f, os.Stdout))
+// Close closes the current log file. Log lines written after Close are
+// discarded if the logger only writes to files.
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	if l.out != nil {
+		l.logger.SetOutput(l.out)
 	} else {
-		logrus.SetOutput(os.Stdout)
-	}
-
-	rtLogConf.showFileInfo = showFileInfo
-	rtLogConf.today = &t
-	rtLogConf.logpath = logpath
-	rtLogConf.file = f
-
-	wg.Add(1)
-	go func() {
-		Debug(context.Background(), "Logger start fetching filename with datetime")
-	loop:
-		for {
-			now := time.Now().UTC()
-			next := now.Truncate(d)
-			nt := next.Unix()
-			t := rtLogConf.today.UTC().Unix()
-
-			if nt > t {
-				path := rtLogConf.logpath + "/" + timeutils.Time2String(&next, "_") + "." + "log"
-				f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-				if err != nil {
-					panic(err)
-				}
-
-				if multiWriter {
-					logrus.SetOutput(io.MultiWriter(f, os.Stdout))
-				} else {
-					logrus.SetOutput(f)
-				}
-
-				if err := rtLogConf.file.Close(); err != nil {
-					panic(err)
-				}
-
-				rtLogConf.today = &next
-				rtLogConf.file = f
-			}
+		l.logger.SetOutput(ioutil.Discard)
+	}
+	return l.file.Close()
+}
 
-			time.Sleep(500 * time.Millisecond)
+// Debug logs a message at level Debug.
+func (l *Logger) Debug(ctx context.Context, args ...interface{}) {
+	l.entry(ctx).Debug(args...)
+}
 
-			select {
-			case <-logExit:
-				break loop
-			default:
-			}
-		}
-		wg.Done()
-	}()
+// Debugf logs a message at level Debug.
+func (l *Logger) Debugf(ctx context.Context, msg string, args ...interface{}) {
+	l.entry(ctx).Debugf(msg, args...)
 }
 
-// Stop ...
-func Stop() {
-	logExit <- nil
-	wg.Wait()
-	Debug(context.Background(), "Logger stop fetching filename with datetime")
+// Info logs a message at level Info.
+func (l *Logger) Info(ctx context.Context, args ...interface{}) {
+	l.entry(ctx).Info(args...)
+}
+
+// Infof logs a message at level Info.
+func (l *Logger) Infof(ctx context.Context, msg string, args ...interface{}) {
+	l.entry(ctx).Infof(msg, args...)
+}
+
+// Warn logs a message at level Warn.
+func (l *Logger) Warn(ctx context.Context, args ...interface{}) {
+	l.entry(ctx).Warn(args...)
+}
+
+// Warnf logs a message at level Warn.
+func (l *Logger) Warnf(ctx context.Context, msg string, args ...interface{}) {
+	l.entry(ctx).Warnf(msg, args...)
+}
+
+// Error logs a message at level Error.
+func (l *Logger) Error(ctx context.Context, args ...interface{}) {
+	l.entry(ctx).Error(args...)
 }
 
-func getBaseName(fileName string, funcName string) (string, string) {
-	return filepath.Base(fileName), filepath.Base(funcName)
+// Errorf logs a message at level Error.
+func (l *Logger) Errorf(ctx context.Context, msg string, args ...interface{}) {
+	l.entry(ctx).Errorf(msg, args...)
 }
 
-// Debug logs a message at level Debug on the standard logger.
+// Panic logs a message at level Panic, then panics.
+func (l *Logger) Panic(ctx context.Context, args ...interface{}) {
+	l.entry(ctx).Panic(args...)
+}
+
+// Panicf logs a message at level Panic, then panics.
+func (l *Logger) Panicf(ctx context.Context, msg string, args ...interface{}) {
+	l.entry(ctx).Panicf(msg, args...)
+}
+
+// Debug logs a message at level Debug on the default logger.
 func Debug(ctx context.Context, args ...interface{}) {
-	message := message(ctx, "Debug", args...)
-	logrus.Debug(message)
+	Default().Debug(ctx, args...)
 }
 
-// Debugf logs a message at level Debug on the standard logger.
+// Debugf logs a message at level Debug on the default logger.
 func Debugf(ctx context.Context, msg string, args ...interface{}) {
-	message := messagef(ctx, "Debug", msg, args...)
-	logrus.Debug(message)
+	Default().Debugf(ctx, msg, args...)
 }
 
-// Info logs a message at level Info on the standard logger.
+// Info logs a message at level Info on the default logger.
 func Info(ctx context.Context, args ...interface{}) {
-	message := message(ctx, "Info", args...)
-	logrus.Info(message)
+	Default().Info(ctx, args...)
 }
 
-// Infof logs a message at level Info on the standard logger.
+// Infof logs a message at level Info on the default logger.
 func Infof(ctx context.Context, msg string, args ...interface{}) {
-	message := messagef(ctx, "Info", msg, args...)
-	logrus.Info(message)
+	Default().Infof(ctx, msg, args...)
 }
 
-// Warn logs a message at level Warn on the standard logger.
+// Warn logs a message at level Warn on the default logger.
 func Warn(ctx context.Context, args ...interface{}) {
-	message := message(ctx, "Warn", args...)
-	logrus.Warn(message)
+	Default().Warn(ctx, args...)
 }
 
-// Warnf logs a message at level Warn on the standard logger.
+// Warnf logs a message at level Warn on the default logger.
 func Warnf(ctx context.Context, msg string, args ...interface{}) {
-	message := messagef(ctx, "Warn", msg, args...)
-	logrus.Warn(message)
+	Default().Warnf(ctx, msg, args...)
 }
 
-// Error logs a message at level Error on the standard logger.
+// Error logs a message at level Error on the default logger.
 func Error(ctx context.Context, args ...interface{}) {
-	message := message(ctx, "Error", args...)
-	logrus.Error(message)
+	Default().Error(ctx, args...)
 }
 
-// Errorf logs a message at level Error on the standard logger.
+// Errorf logs a message at level Error on the default logger.
 func Errorf(ctx context.Context, msg string, args ...interface{}) {
-	message := messagef(ctx, "Error", msg, args...)
-	logrus.Error(message)
+	Default().Errorf(ctx, msg, args...)
 }
 
-// Panic logs a message at level Panic on the standard logger.
+// Panic logs a message at level Panic on the default logger.
 func Panic(ctx context.Context, args ...interface{}) {
-	message := message(ctx, "Panic", args...)
-	logrus.Panic(message)
+	Default().Panic(ctx, args...)
 }
 
-// Panicf logs a message at level Panic on the standard logger.
+// Panicf logs a message at level Panic on the default logger.
 func Panicf(ctx context.Context, msg string, args ...interface{}) {
-	message := messagef(ctx, "Panic", msg, args...)
-	logrus.Panic(message)
-}
-
-func message(ctx context.Context, level string, msg ...interface{}) string {
-	message := Message{
-		ChainID:     "",
-		Level:       level,
-		Version:     "v1.0.0",
-		ServiceCode: "100",
-		Time:        time.Now().UTC().Format(time.RFC3339),
-		Msg:         fmt.Sprint(msg...),
-	}
-	if chainID := ctx.Value("ChainID"); chainID != nil {
-		message.ChainID = chainID.(string)
-	}
-	m, _ := json.Marshal(message)
-
-	return string(m)
-}
-
-func messagef(ctx context.Context, level string, msg string, args ...interface{}) string {
-	message := Message{
-		ChainID:     "",
-		Level:       level,
-		Version:     "v1.0.0",
-		ServiceCode: "100",
-		Time:        time.Now().UTC().Format(time.RFC3339),
-		Msg:         fmt.Sprintf(msg, args...),
-	}
-	if chainID := ctx.Value("ChainID"); chainID != nil {
-		message.ChainID = chainID.(string)
-	}
-	m, _ := json.Marshal(message)
-
-	return string(m)
+	Default().Panicf(ctx, msg, args...)
 }
@@ -0,0 +1,181 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func decodeLines(t *testing.T, b []byte) []Message {
+	t.Helper()
+	var msgs []Message
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var m Message
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
+
+func TestLoggerOutput(t *testing.T) {
+	var buf1, buf2 bytes.Buffer
+	l1, err := New(Config{ServiceName: "svc1", Version: "v2", ServiceCode: "7", InstanceID: "i1", Output: &buf1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2, err := New(Config{ServiceName: "svc2", Level: "warn", Output: &buf2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithTrace(WithChainID(context.Background(), "chain"), TraceContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  "00f067aa0ba902b7",
+	})
+	l1.WithFields(ctx, Fields{"n": 1}).Infof("hello %s", "world")
+	l2.Info(ctx, "dropped")
+	l2.Warn(ctx, "kept")
+
+	got := decodeLines(t, buf1.Bytes())
+	want := Message{
+		ChainID:     "chain",
+		TraceID:     "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:      "00f067aa0ba902b7",
+		Level:       "Info",
+		Service:     "svc1",
+		Version:     "v2",
+		ServiceCode: "7",
+		InstanceID:  "i1",
+		Msg:         "hello world",
+		Fields:      Fields{"n": 1.0},
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d lines, want 1", len(got))
+	}
+	got[0].Time = ""
+	if !jsonEqual(got[0], want) {
+		t.Errorf("wrong message\ngot  %+v\nwant %+v", got[0], want)
+	}
+
+	got = decodeLines(t, buf2.Bytes())
+	if len(got) != 1 || got[0].Msg != "kept" || got[0].Service != "svc2" {
+		t.Errorf("wrong output of second logger: %s", buf2.String())
+	}
+}
+
+// User fields named like the identity and trace keys must not replace them.
+func TestFieldsCollision(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(Config{ServiceName: "svc", Version: "v2", ServiceCode: "7", InstanceID: "i1", Output: &buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithTrace(WithChainID(context.Background(), "chain"), TraceContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  "00f067aa0ba902b7",
+	})
+	user := Fields{
+		"chainID":     "c",
+		"service":     "s",
+		"version":     "v",
+		"serviceCode": "sc",
+		"instanceID":  "i",
+		"traceID":     "t",
+		"spanID":      "sp",
+	}
+	l.WithFields(ctx, user).Info("collision")
+
+	got := decodeLines(t, buf.Bytes())
+	want := Message{
+		ChainID:     "chain",
+		TraceID:     "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:      "00f067aa0ba902b7",
+		Level:       "Info",
+		Service:     "svc",
+		Version:     "v2",
+		ServiceCode: "7",
+		InstanceID:  "i1",
+		Msg:         "collision",
+		Fields:      user,
+	}
+	got[0].Time = ""
+	if !jsonEqual(got[0], want) {
+		t.Errorf("wrong message\ngot  %+v\nwant %+v", got[0], want)
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
+
+func TestLoggerFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	l, err := New(Config{Path: dir, Duration: "24h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info(context.Background(), "to file")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*_*.log"))
+	if len(files) != 1 {
+		t.Fatalf("got log files %v, want one", files)
+	}
+	content, _ := ioutil.ReadFile(files[0])
+	if msgs := decodeLines(t, content); msgs[0].Msg != "to file" {
+		t.Errorf("wrong file content %q", content)
+	}
+}
+
+func TestInitSamePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer SetDefault(Default())
+
+	// The second call must not fail on the directory locked by the
+	// logger created by the first one.
+	for i := 0; i < 2; i++ {
+		InitWithConfig(Config{Path: dir, Duration: "24h"})
+		Info(context.Background(), "line")
+	}
+	Stop()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*_*.log"))
+	if len(files) != 1 {
+		t.Fatalf("got log files %v, want one", files)
+	}
+	content, _ := ioutil.ReadFile(files[0])
+	if msgs := decodeLines(t, content); len(msgs) != 2 {
+		t.Errorf("got %d lines, want 2", len(msgs))
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{Level: "loud"},
+		{SlackURL: "http://localhost", SlackLevel: "loud"},
+		{Path: "logs", Duration: "soon"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("no error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package log
+
+import "net/http"
+
+// Header names used by Middleware.
+const (
+	ChainIDHeader     = "X-Chain-ID"
+	TraceparentHeader = "traceparent"
+)
+
+// Middleware adds the chain ID and trace context of each request to its
+// context, so that log lines written while handling it carry them.
+//
+// The chain ID is taken from the X-Chain-ID header. If the request has
+// none, the trace ID is used. A valid traceparent header continues the
+// caller's trace in a new span, otherwise a new trace is started. Both
+// IDs are returned in the response headers.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace, err := ParseTraceparent(r.Header.Get(TraceparentHeader))
+		if err != nil {
+			trace = NewTraceContext()
+		} else {
+			trace = trace.NewSpan()
+		}
+		chainID := r.Header.Get(ChainIDHeader)
+		if chainID == "" {
+			chainID = trace.TraceID
+		}
+
+		w.Header().Set(ChainIDHeader, chainID)
+		w.Header().Set(TraceparentHeader, trace.Traceparent())
+		ctx := WithTrace(WithChainID(r.Context(), chainID), trace)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
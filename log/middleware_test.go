@@ -0,0 +1,67 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tests := []struct {
+		name        string
+		traceparent string
+		chainID     string
+	}{
+		{name: "new trace"},
+		{name: "continued trace", traceparent: parent},
+		{name: "chain ID", traceparent: parent, chainID: "chain-1"},
+	}
+	for _, test := range tests {
+		var (
+			gotTrace   TraceContext
+			gotOK      bool
+			gotChainID string
+		)
+		h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotTrace, gotOK = TraceFrom(r.Context())
+			gotChainID = ChainIDFrom(r.Context())
+		}))
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.traceparent != "" {
+			req.Header.Set(TraceparentHeader, test.traceparent)
+		}
+		if test.chainID != "" {
+			req.Header.Set(ChainIDHeader, test.chainID)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if !gotOK {
+			t.Errorf("%s: no trace in request context", test.name)
+			continue
+		}
+		if test.traceparent != "" {
+			caller, _ := ParseTraceparent(test.traceparent)
+			if gotTrace.TraceID != caller.TraceID || gotTrace.Sampled != caller.Sampled {
+				t.Errorf("%s: trace %+v doesn't continue %+v", test.name, gotTrace, caller)
+			}
+			if gotTrace.SpanID == caller.SpanID {
+				t.Errorf("%s: span ID not changed", test.name)
+			}
+		}
+		wantChainID := test.chainID
+		if wantChainID == "" {
+			wantChainID = gotTrace.TraceID
+		}
+		if gotChainID != wantChainID {
+			t.Errorf("%s: chain ID %q in context, want %q", test.name, gotChainID, wantChainID)
+		}
+		if h := rec.Header().Get(ChainIDHeader); h != wantChainID {
+			t.Errorf("%s: %s header %q, want %q", test.name, ChainIDHeader, h, wantChainID)
+		}
+		if h := rec.Header().Get(TraceparentHeader); h != gotTrace.Traceparent() {
+			t.Errorf("%s: %s header %q, want %q", test.name, TraceparentHeader, h, gotTrace.Traceparent())
+		}
+	}
+}
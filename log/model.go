@@ -1,10 +1,19 @@
 package log
 
+// Message is the schema of a log line written by JSONFormatter.
 type Message struct {
 	ChainID     string `json:"chainID"`
+	TraceID     string `json:"traceID,omitempty"`
+	SpanID      string `json:"spanID,omitempty"`
 	Level       string `json:"level"`
+	Service     string `json:"service,omitempty"`
 	Version     string `json:"version"`
 	ServiceCode string `json:"serviceCode"`
+	InstanceID  string `json:"instanceID,omitempty"`
 	Time        string `json:"time"`
 	Msg         string `json:"msg"`
+	Fields      Fields `json:"fields,omitempty"`
 }
+
+// Fields holds structured data attached to a log line.
+type Fields map[string]interface{}
@@ -0,0 +1,340 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Yamiyo/common/timeutils"
+)
+
+// currentLogName is the name of the symlink to the log file being written.
+const currentLogName = "current.log"
+
+// lockFileName is the name of the file locked by the rotatingFile using
+// a directory.
+const lockFileName = ".lock"
+
+// logFileName matches the names of log files written by rotatingFile.
+var logFileName = regexp.MustCompile(`^\d{4}(_\d{2}){5}(\.\d+)?\.log(\.gz)?$`)
+
+var errLogFileClosed = errors.New("log file is closed")
+
+// rotateRetryInterval is how long writes continue to the current file
+// after switching to a new one failed.
+const rotateRetryInterval = 10 * time.Second
+
+// rotatingFile writes log lines to files in a directory. A new file is
+// started by the first write of each period and when the current file
+// would grow beyond maxSize. Files are named after the beginning of their
+// period, with a sequence number if a period has more than one file.
+//
+// Rotation happens in Write, so nothing runs while no lines are logged.
+// After each rotation, a background goroutine compresses the closed file
+// and deletes files beyond the retention limits.
+//
+// Since cleaning up treats every log file in dir except the current one
+// as closed, a rotatingFile locks dir for its lifetime, and creating a
+// second one for the same directory fails until the first is closed.
+type rotatingFile struct {
+	dir      string
+	period   time.Duration // zero disables time-based rotation
+	maxSize  int64         // zero disables size-based rotation
+	maxAge   time.Duration // zero keeps files of any age
+	maxFiles int           // zero keeps any number of files
+	compress bool
+	onError  func(error) // reports errors that don't fail a write
+	lock     *os.File    // holds the lock on dir, nil if unsupported
+
+	mu        sync.Mutex
+	now       func() time.Time
+	nextRetry time.Time // no rotation before this time after a failure
+	file      *os.File
+	name      string    // base name of file
+	size      int64     // size of file
+	start     time.Time // beginning of the period of file
+	seq       int       // sequence number of file within its period
+
+	cleanup chan struct{} // wakes the cleanup goroutine
+	done    chan struct{} // closed when the cleanup goroutine exits
+}
+
+func newRotatingFile(dir string, period time.Duration, maxSize int64, maxAge time.Duration, maxFiles int, compress bool) (*rotatingFile, error) {
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		return nil, fmt.Errorf("error folder create: %v", err)
+	}
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{
+		dir:      dir,
+		period:   period,
+		maxSize:  maxSize,
+		maxAge:   maxAge,
+		maxFiles: maxFiles,
+		compress: compress,
+		onError:  printRotateError,
+		lock:     lock,
+		now:      time.Now,
+		cleanup:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	if err := r.open(r.now().UTC()); err != nil {
+		if lock != nil {
+			lock.Close()
+		}
+		return nil, err
+	}
+	go r.cleanupLoop()
+	return r, nil
+}
+
+// periodStart returns the beginning of the period containing t.
+func (r *rotatingFile) periodStart(t time.Time) time.Time {
+	if r.period > 0 {
+		return t.Truncate(r.period)
+	}
+	return t.Truncate(time.Second)
+}
+
+// open switches to the log file for time t. It continues the last file
+// of the period if that has room, so restarts don't create new files.
+func (r *rotatingFile) open(t time.Time) error {
+	start := r.periodStart(t)
+	seq, room := r.lastFile(start)
+	if r.file != nil && start.Equal(r.start) {
+		// Rotating within the period, the current file is full.
+		if seq < r.seq {
+			seq = r.seq
+		}
+		room = false
+	}
+	if seq < 0 {
+		seq = 0
+	} else if !room {
+		seq++
+	}
+	name := logName(start, seq)
+
+	f, err := os.OpenFile(filepath.Join(r.dir, name), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if r.file != nil {
+		r.file.Close()
+		r.wakeCleanup()
+	}
+	r.file, r.name, r.size, r.start, r.seq = f, name, fi.Size(), start, seq
+	r.link(name)
+	return nil
+}
+
+// logName returns the name of the log file with sequence number seq in
+// the period beginning at start.
+func logName(start time.Time, seq int) string {
+	name := timeutils.Time2String(&start, "_")
+	if seq > 0 {
+		name += fmt.Sprintf(".%d", seq)
+	}
+	return name + ".log"
+}
+
+// lastFile returns the highest sequence number of the files in r.dir
+// for the period beginning at start, or -1 if there are none. It also
+// reports whether that file can be continued, i.e. it isn't compressed
+// and is smaller than maxSize.
+func (r *rotatingFile) lastFile(start time.Time) (seq int, room bool) {
+	entries, err := ioutil.ReadDir(r.dir)
+	if err != nil {
+		return -1, false
+	}
+	prefix := timeutils.Time2String(&start, "_")
+	seq = -1
+	for _, fi := range entries {
+		name := fi.Name()
+		if !fi.Mode().IsRegular() || !logFileName.MatchString(name) || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		n := 0
+		if rest := strings.TrimSuffix(name[len(prefix):], ".gz"); rest != ".log" {
+			n, _ = strconv.Atoi(strings.TrimSuffix(rest[1:], ".log"))
+		}
+		// A .gz file sorts after the .log file of the same name, so a
+		// file that is being compressed isn't continued.
+		if n >= seq {
+			seq = n
+			room = filepath.Ext(name) == ".log" && (r.maxSize == 0 || fi.Size() < r.maxSize)
+		}
+	}
+	return seq, room
+}
+
+// link points the current.log symlink at the file name. Errors are
+// ignored since the link is only a convenience, and not every file
+// system supports it.
+func (r *rotatingFile) link(name string) {
+	tmp := filepath.Join(r.dir, currentLogName+".tmp")
+	os.Remove(tmp)
+	if err := os.Symlink(name, tmp); err == nil {
+		os.Rename(tmp, filepath.Join(r.dir, currentLogName))
+	}
+}
+
+func (r *rotatingFile) wakeCleanup() {
+	select {
+	case r.cleanup <- struct{}{}:
+	default:
+	}
+}
+
+// Write implements io.Writer. If switching to a new file fails, the error
+// is passed to onError, b is written to the current file and the switch
+// is retried after rotateRetryInterval.
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, errLogFileClosed
+	}
+	now := r.now().UTC()
+	if r.needRotate(now, len(b)) && !now.Before(r.nextRetry) {
+		if err := r.open(now); err != nil {
+			r.nextRetry = now.Add(rotateRetryInterval)
+			r.onError(err)
+		}
+	}
+	n, err := r.file.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// needRotate reports whether writing n bytes at time t needs a new file.
+func (r *rotatingFile) needRotate(t time.Time, n int) bool {
+	if r.period > 0 && !r.periodStart(t).Equal(r.start) {
+		return true
+	}
+	return r.maxSize > 0 && r.size > 0 && r.size+int64(n) > r.maxSize
+}
+
+func printRotateError(err error) {
+	fmt.Fprintf(os.Stderr, "log: can't switch to a new log file: %v\n", err)
+}
+
+// Close closes the current file, waits for the cleanup goroutine and
+// releases the lock on the directory.
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	if r.file == nil {
+		r.mu.Unlock()
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	close(r.cleanup)
+	r.mu.Unlock()
+
+	<-r.done
+	if r.lock != nil {
+		r.lock.Close()
+	}
+	return err
+}
+
+func (r *rotatingFile) cleanupLoop() {
+	defer close(r.done)
+	r.clean()
+	for range r.cleanup {
+		r.clean()
+	}
+}
+
+// clean compresses closed log files and deletes old ones.
+func (r *rotatingFile) clean() {
+	entries, err := ioutil.ReadDir(r.dir)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	current, now := r.name, r.now()
+	r.mu.Unlock()
+
+	var files []os.FileInfo
+	for _, fi := range entries {
+		name := fi.Name()
+		if !fi.Mode().IsRegular() || name == current || !logFileName.MatchString(name) {
+			continue
+		}
+		if r.compress && filepath.Ext(name) == ".log" {
+			if gz, err := r.gzip(fi); err == nil {
+				fi = gz
+			}
+		}
+		files = append(files, fi)
+	}
+
+	// Newest files first.
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+	for i, fi := range files {
+		tooOld := r.maxAge > 0 && now.Sub(fi.ModTime()) > r.maxAge
+		tooMany := r.maxFiles > 0 && i >= r.maxFiles
+		if tooOld || tooMany {
+			os.Remove(filepath.Join(r.dir, fi.Name()))
+		}
+	}
+}
+
+// gzip compresses a log file into a .gz file with the same modification
+// time, then removes it.
+func (r *rotatingFile) gzip(fi os.FileInfo) (os.FileInfo, error) {
+	path := filepath.Join(r.dir, fi.Name())
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, fi.ModTime(), fi.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	os.Remove(path)
+	return os.Stat(path + ".gz")
+}
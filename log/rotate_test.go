@@ -0,0 +1,344 @@
+package log
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempLogDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "logtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// listLogs returns the names of the files in dir, except current.log and
+// the lock file.
+func listLogs(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fi := range entries {
+		if fi.Name() != currentLogName && fi.Name() != lockFileName {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *rotatingFile) setNow(t time.Time) {
+	r.mu.Lock()
+	r.now = func() time.Time { return t }
+	r.mu.Unlock()
+}
+
+func TestRotateSize(t *testing.T) {
+	dir := tempLogDir(t)
+	defer os.RemoveAll(dir)
+
+	r, err := newRotatingFile(dir, 0, 10, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.setNow(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cc\n", "dddddddddddddddd\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link, err := os.Readlink(filepath.Join(dir, currentLogName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first file is named after the time the logger was created.
+	want := map[string]string{
+		"2021_03_04_05_06_07.log":   "bbbbbb\ncc\n",
+		"2021_03_04_05_06_07.1.log": "dddddddddddddddd\n",
+	}
+	names := listLogs(t, dir)
+	if len(names) != 3 {
+		t.Fatalf("got files %v, want 3", names)
+	}
+	for name, content := range want {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("%s contains %q, want %q", name, b, content)
+		}
+	}
+	if link != "2021_03_04_05_06_07.1.log" {
+		t.Errorf("current.log links to %q", link)
+	}
+}
+
+func TestRotatePeriod(t *testing.T) {
+	dir := tempLogDir(t)
+	defer os.RemoveAll(dir)
+
+	r, err := newRotatingFile(dir, time.Hour, 0, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := r.name
+	start := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	for _, d := range []time.Duration{0, 30 * time.Minute, time.Hour} {
+		r.setNow(start.Add(d))
+		if _, err := r.Write([]byte("line\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"2021_03_04_05_00_00.log", "2021_03_04_06_00_00.log", first}
+	sort.Strings(want)
+	if names := listLogs(t, dir); !reflect.DeepEqual(names, want) {
+		t.Errorf("got files %v, want %v", names, want)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "2021_03_04_05_00_00.log"))
+	if string(b) != "line\nline\n" {
+		t.Errorf("wrong content %q", b)
+	}
+}
+
+func TestRotateResume(t *testing.T) {
+	dir := tempLogDir(t)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 2; i++ {
+		r, err := newRotatingFile(dir, 24*time.Hour, 0, 0, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Write([]byte("line\n"))
+		r.Close()
+	}
+	names := listLogs(t, dir)
+	if len(names) != 1 {
+		t.Fatalf("got files %v, want one", names)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, names[0]))
+	if string(b) != "line\nline\n" {
+		t.Errorf("wrong content %q", b)
+	}
+}
+
+func TestRotateResumeSeq(t *testing.T) {
+	dir := tempLogDir(t)
+	defer os.RemoveAll(dir)
+
+	r, err := newRotatingFile(dir, 24*time.Hour, 10, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := r.start
+	r.setNow(start)
+	for _, line := range []string{"aaaaa\n", "bbbbb\n", "ccccc\n"} {
+		r.Write([]byte(line))
+	}
+	r.Close()
+	if want := logName(start, 2); r.name != want {
+		t.Fatalf("wrote to %s, want %s", r.name, want)
+	}
+
+	// All files have room left. The restarted logger continues the
+	// newest one until it is full, and starts the next one after that.
+	for _, want := range []string{logName(start, 2), logName(start, 3)} {
+		r, err := newRotatingFile(dir, 24*time.Hour, 10, 0, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.setNow(start)
+		r.Write([]byte("fff\n"))
+		r.Close()
+		if r.name != want {
+			t.Errorf("resumed with %s, want %s", r.name, want)
+		}
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, logName(start, 2)))
+	if string(b) != "ccccc\nfff\n" {
+		t.Errorf("wrong content %q", b)
+	}
+	if names := listLogs(t, dir); len(names) != 4 {
+		t.Errorf("got files %v, want 4", names)
+	}
+}
+
+func TestRotateLock(t *testing.T) {
+	dir := tempLogDir(t)
+	defer os.RemoveAll(dir)
+
+	r, err := newRotatingFile(dir, 0, 0, 0, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.lock == nil {
+		t.Skip("directory locking is not supported")
+	}
+	// A second rotatingFile would compress the file in use.
+	if r2, err := newRotatingFile(dir, 0, 0, 0, 0, true); err == nil {
+		r2.Close()
+		t.Fatal("no error for directory in use")
+	}
+	if _, err := os.Stat(filepath.Join(dir, r.name)); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	r, err = newRotatingFile(dir, 0, 0, 0, 0, true)
+	if err != nil {
+		t.Fatalf("error after close: %v", err)
+	}
+	r.Close()
+}
+
+func TestRotateCompress(t *testing.T) {
+	dir := tempLogDir(t)
+	defer os.RemoveAll(dir)
+
+	r, err := newRotatingFile(dir, 0, 5, 0, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.setNow(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	r.Write([]byte("first\n"))
+	r.Write([]byte("second\n"))
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file opened at startup holds the first line, the second one
+	// started a new file.
+	var compressed []string
+	for _, name := range listLogs(t, dir) {
+		if strings.HasSuffix(name, ".gz") {
+			compressed = append(compressed, name)
+		}
+	}
+	if len(compressed) != 1 {
+		t.Fatalf("got compressed files %v, want one", compressed)
+	}
+	f, err := os.Open(filepath.Join(dir, compressed[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(zr); string(b) != "first\n" {
+		t.Errorf("wrong content %q", b)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "2021_03_04_05_06_07.log")); string(b) != "second\n" {
+		t.Errorf("current file contains %q", b)
+	}
+}
+
+func TestRotateRetention(t *testing.T) {
+	dir := tempLogDir(t)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	old := map[string]time.Duration{
+		"2021_03_01_00_00_00.log":      72 * time.Hour,
+		"2021_03_02_00_00_00.log.gz":   48 * time.Hour,
+		"2021_03_03_00_00_00.log":      3 * time.Hour,
+		"2021_03_03_00_00_00.1.log.gz": 2 * time.Hour,
+		"2021_03_03_00_00_00.2.log":    time.Hour,
+		"notes.txt":                    72 * time.Hour,
+	}
+	for name, age := range old {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := newRotatingFile(dir, 24*time.Hour, 0, 24*time.Hour, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := r.name
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{current, "2021_03_03_00_00_00.1.log.gz", "2021_03_03_00_00_00.2.log", "notes.txt"}
+	sort.Strings(want)
+	if names := listLogs(t, dir); !reflect.DeepEqual(names, want) {
+		t.Errorf("got files %v, want %v", names, want)
+	}
+}
+
+func TestRotateError(t *testing.T) {
+	dir := tempLogDir(t)
+	defer os.RemoveAll(dir)
+
+	// A directory in place of the next log file makes switching fail.
+	start := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	blocker := filepath.Join(dir, "2021_03_04_05_06_07.log")
+	if err := os.Mkdir(blocker, 0755); err != nil {
+		t.Fatal(err)
+	}
+	r, err := newRotatingFile(dir, 0, 5000, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := r.name
+	var errs []error
+	r.onError = func(err error) { errs = append(errs, err) }
+	r.setNow(start)
+
+	line := []byte(strings.Repeat("x", 2999) + "\n")
+	for i := 0; i < 3; i++ {
+		if n, err := r.Write(line); n != len(line) || err != nil {
+			t.Fatalf("write %d: n=%d, err=%v", i, n, err)
+		}
+	}
+	// The failed switch is reported once and not retried immediately.
+	if len(errs) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errs), errs)
+	}
+	if r.name != first {
+		t.Fatalf("switched to %s", r.name)
+	}
+
+	os.Remove(blocker)
+	r.setNow(start.Add(rotateRetryInterval))
+	if _, err := r.Write(line); err != nil {
+		t.Fatal(err)
+	}
+	if r.name != "2021_03_04_05_06_17.log" {
+		t.Errorf("wrong file %s after retry", r.name)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 {
+		t.Errorf("got %d errors, want 1: %v", len(errs), errs)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// The encodings of signed integers, floats and times are specified in doc.go.
+// Hashes are computed over these bytes, so the tables below must not change.
+
+var conformanceTests = []struct {
+	val    interface{}
+	output string
+}{
+	// int8
+	{val: int8(0), output: "80"},
+	{val: int8(1), output: "820001"},
+	{val: int8(-1), output: "820101"},
+	{val: int8(127), output: "82007F"},
+	{val: int8(-127), output: "82017F"},
+	{val: int8(-128), output: "820180"},
+
+	// int16
+	{val: int16(128), output: "820080"},
+	{val: int16(-128), output: "820180"},
+	{val: int16(255), output: "8200FF"},
+	{val: int16(256), output: "83000100"},
+	{val: int16(-256), output: "83010100"},
+	{val: int16(math.MaxInt16), output: "83007FFF"},
+	{val: int16(math.MinInt16), output: "83018000"},
+
+	// int32
+	{val: int32(0xFFFFFF), output: "8400FFFFFF"},
+	{val: int32(0x1000000), output: "850001000000"},
+	{val: int32(math.MaxInt32), output: "85007FFFFFFF"},
+	{val: int32(math.MinInt32), output: "850180000000"},
+
+	// int64 and int
+	{val: int64(0), output: "80"},
+	{val: int64(math.MaxInt64), output: "89007FFFFFFFFFFFFFFF"},
+	{val: int64(math.MinInt64), output: "89018000000000000000"},
+	{val: int64(math.MinInt64 + 1), output: "89017FFFFFFFFFFFFFFF"},
+	{val: int(-3), output: "820103"},
+	{val: int(1 << 32), output: "86000100000000"},
+
+	// float64
+	{val: float64(0), output: "80"},
+	{val: float64(1), output: "883FF0000000000000"},
+	{val: float64(-1), output: "88BFF0000000000000"},
+	{val: float64(-2.5), output: "88C004000000000000"},
+	{val: float64(0.1), output: "883FB999999999999A"},
+	{val: math.Copysign(0, -1), output: "888000000000000000"},
+	{val: math.SmallestNonzeroFloat64, output: "8101"},
+	{val: math.Float64frombits(0x7F), output: "817F"},
+	{val: math.Float64frombits(0x80), output: "8180"},
+	{val: math.MaxFloat64, output: "887FEFFFFFFFFFFFFF"},
+	{val: math.Inf(1), output: "887FF0000000000000"},
+	{val: math.Inf(-1), output: "88FFF0000000000000"},
+	{val: math.NaN(), output: "887FF8000000000001"},
+	{val: math.Float64frombits(0xFFF8000000000000), output: "88FFF8000000000000"},
+
+	// float32
+	{val: float32(0), output: "80"},
+	{val: float32(1.5), output: "883FF8000000000000"},
+	{val: float32(-0.25), output: "88BFD0000000000000"},
+	{val: float32(math.MaxFloat32), output: "8847EFFFFFE0000000"},
+	{val: float32(math.SmallestNonzeroFloat32), output: "8836A0000000000000"},
+	{val: float32(math.Inf(-1)), output: "88FFF0000000000000"},
+	{val: float32(math.Copysign(0, -1)), output: "888000000000000000"},
+	{val: float32(math.NaN()), output: "887FF8000000000000"},
+
+	// time.Time
+	{val: time.Time{}, output: "8F01000000000000000000000000FFFF"},
+	{val: time.Unix(0, 0).UTC(), output: "8F010000000E7791F70000000000FFFF"},
+	{val: time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC), output: "8F010000000ED59F54A500000006FFFF"},
+	{val: time.Date(2021, 12, 31, 23, 59, 59, 999999999, time.FixedZone("", 8*3600)), output: "8F010000000ED9611FFF3B9AC9FF01E0"},
+	{val: time.Date(2000, 1, 1, 0, 0, 0, 0, time.FixedZone("", 0)), output: "8F010000000EAFFF3A80000000000000"},
+	{val: time.Date(2000, 1, 1, 0, 0, 0, 0, time.FixedZone("", -3630)), output: "90020000000EAFFF48AE00000000FFC4E2"},
+}
+
+func TestConformanceEncode(t *testing.T) {
+	for i, test := range conformanceTests {
+		output, err := EncodeToBytes(test.val)
+		if err != nil {
+			t.Errorf("test %d: unexpected error encoding %T %v: %v", i, test.val, test.val, err)
+			continue
+		}
+		if want := unhex(test.output); !bytes.Equal(output, want) {
+			t.Errorf("test %d: output mismatch for %T %v:\ngot  %X\nwant %s", i, test.val, test.val, output, test.output)
+		}
+	}
+}
+
+func TestConformanceDecode(t *testing.T) {
+	for i, test := range conformanceTests {
+		ptr := reflect.New(reflect.TypeOf(test.val))
+		if err := DecodeBytes(unhex(test.output), ptr.Interface()); err != nil {
+			t.Errorf("test %d: unexpected error decoding %s into %T: %v", i, test.output, test.val, err)
+			continue
+		}
+		if got := ptr.Elem().Interface(); !conformanceEqual(got, test.val) {
+			t.Errorf("test %d: decoded value mismatch for %s:\ngot  %v\nwant %v", i, test.output, got, test.val)
+		}
+	}
+}
+
+func conformanceEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case float64:
+		return math.Float64bits(a) == math.Float64bits(b.(float64))
+	case float32:
+		return math.Float32bits(a) == math.Float32bits(b.(float32))
+	case time.Time:
+		bt := b.(time.Time)
+		_, aoff := a.Zone()
+		_, boff := bt.Zone()
+		return a.Equal(bt) && aoff == boff
+	default:
+		return a == b
+	}
+}
+
+var conformanceRejectTests = []decodeTest{
+	// signed integers
+	{input: "00", ptr: new(int64), error: "rlp: non-canonical sign byte for int64 (Byte at offset 0x0)"},
+	{input: "05", ptr: new(int64), error: "rlp: non-canonical sign byte for int64 (Byte at offset 0x0)"},
+	{input: "8180", ptr: new(int64), error: "rlp: non-canonical sign byte for int64 (String at offset 0x0)"},
+	{input: "820205", ptr: new(int64), error: "rlp: non-canonical sign byte for int64 (String at offset 0x0)"},
+	{input: "82FF05", ptr: new(int64), error: "rlp: non-canonical sign byte for int64 (String at offset 0x0)"},
+	{input: "820000", ptr: new(int64), error: "rlp: non-canonical integer (leading zero bytes) for int64 (String at offset 0x0)"},
+	{input: "820100", ptr: new(int64), error: "rlp: non-canonical integer (leading zero bytes) for int64 (String at offset 0x0)"},
+	{input: "83000005", ptr: new(int64), error: "rlp: non-canonical integer (leading zero bytes) for int64 (String at offset 0x0)"},
+	{input: "83010005", ptr: new(int64), error: "rlp: non-canonical integer (leading zero bytes) for int64 (String at offset 0x0)"},
+	{input: "B8020005", ptr: new(int64), error: "rlp: non-canonical size information for int64 (String at offset 0x0)"},
+	{input: "C0", ptr: new(int64), error: "rlp: expected input string or byte for int64 (List at offset 0x0)"},
+	{input: "820080", ptr: new(int8), error: "rlp: integer out of range for int8 (String at offset 0x0)"},
+	{input: "820181", ptr: new(int8), error: "rlp: integer out of range for int8 (String at offset 0x0)"},
+	{input: "83000100", ptr: new(int8), error: "rlp: input string too long for int8 (String at offset 0x0)"},
+	{input: "83008000", ptr: new(int16), error: "rlp: integer out of range for int16 (String at offset 0x0)"},
+	{input: "83018001", ptr: new(int16), error: "rlp: integer out of range for int16 (String at offset 0x0)"},
+	{input: "850080000000", ptr: new(int32), error: "rlp: integer out of range for int32 (String at offset 0x0)"},
+	{input: "89008000000000000000", ptr: new(int64), error: "rlp: integer out of range for int64 (String at offset 0x0)"},
+	{input: "89018000000000000001", ptr: new(int64), error: "rlp: integer out of range for int64 (String at offset 0x0)"},
+	{input: "8A00010000000000000000", ptr: new(int64), error: "rlp: input string too long for int64 (String at offset 0x0)"},
+
+	// floats
+	{input: "00", ptr: new(float64), error: "rlp: non-canonical float (single byte without string header) for float64 (Byte at offset 0x0)"},
+	{input: "05", ptr: new(float64), error: "rlp: non-canonical float (single byte without string header) for float64 (Byte at offset 0x0)"},
+	{input: "8100", ptr: new(float64), error: "rlp: non-canonical integer (leading zero bytes) for float64 (String at offset 0x0)"},
+	{input: "820001", ptr: new(float64), error: "rlp: non-canonical integer (leading zero bytes) for float64 (String at offset 0x0)"},
+	{input: "887FF0000000000001", ptr: new(float32), error: "rlp: value not representable for float32 (String at offset 0x0)"},
+	{input: "89003FF0000000000000", ptr: new(float64), error: "rlp: input string too long for float64 (String at offset 0x0)"},
+	{input: "C0", ptr: new(float64), error: "rlp: expected input string or byte for float64 (List at offset 0x0)"},
+	{input: "883FB999999999999A", ptr: new(float32), error: "rlp: value not representable for float32 (String at offset 0x0)"},
+	{input: "887FEFFFFFFFFFFFFF", ptr: new(float32), error: "rlp: value not representable for float32 (String at offset 0x0)"},
+
+	// time.Time
+	{input: "80", ptr: new(time.Time), error: "Time.UnmarshalBinary: no data"},
+	{input: "8F030000000E7791F70000000000FFFF", ptr: new(time.Time), error: "Time.UnmarshalBinary: unsupported version"},
+	{input: "90010000000E7791F70000000000FFFF00", ptr: new(time.Time), error: "Time.UnmarshalBinary: invalid length"},
+	{input: "90020000000E7791F70000000000FFFF00", ptr: new(time.Time), error: "rlp: non-canonical time format for time.Time (String at offset 0x0)"},
+	{input: "90020000000E7791F70000000000FFFF00", ptr: new(*time.Time), error: "rlp: non-canonical time format for *time.Time (String at offset 0x0)"},
+	{input: "C0", ptr: new(time.Time), error: "rlp: expected input string or byte for time.Time (List at offset 0x0)"},
+}
+
+func TestConformanceReject(t *testing.T) {
+	for i, test := range conformanceRejectTests {
+		err := DecodeBytes(unhex(test.input), test.ptr)
+		if fmt.Sprint(err) != test.error {
+			t.Errorf("test %d: error mismatch for %s into %T\ngot  %v\nwant %s", i, test.input, test.ptr, err, test.error)
+		}
+	}
+}
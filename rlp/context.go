@@ -0,0 +1,144 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ContextError is returned when decoding stops because a context is done.
+// Err is the error of the context, so errors.Is(err, context.Canceled) and
+// errors.Is(err, context.DeadlineExceeded) work as expected.
+type ContextError struct {
+	Err error
+}
+
+func (e *ContextError) Error() string {
+	return fmt.Sprintf("rlp: decoding stopped: %v", e.Err)
+}
+
+func (e *ContextError) Unwrap() error {
+	return e.Err
+}
+
+// readDeadliner is implemented by readers with deadlines, such as net.Conn.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// NewStreamContext is like NewStream, but decoding stops with a
+// *ContextError once ctx is done. The context is checked before each
+// value is read. If r has a SetReadDeadline method, like net.Conn, reads
+// that block are also interrupted: the deadline of ctx is applied to each
+// read, and cancellation moves the read deadline into the past. The
+// stream owns the read deadline of r while it is in use.
+//
+// Calling Reset on the returned stream discards the context.
+func NewStreamContext(ctx context.Context, r io.Reader, inputLimit uint64) *Stream {
+	s := NewStream(r, inputLimit)
+	s.ctxr = &contextReader{ctx: ctx, r: r}
+	s.ctxr.conn, _ = r.(readDeadliner)
+	s.r = bufio.NewReader(s.ctxr)
+	s.ctx = ctx
+	return s
+}
+
+// DecodeContext is like Decode, but stops with a *ContextError once ctx
+// is done. For the duration of the call, ctx replaces the context given
+// to NewStreamContext. Blocking reads can only be interrupted if the
+// stream was created by NewStreamContext; other streams check ctx
+// between values.
+func (s *Stream) DecodeContext(ctx context.Context, val interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return &ContextError{err}
+	}
+	prev := s.ctx
+	s.ctx = ctx
+	if s.ctxr != nil {
+		s.ctxr.ctx = ctx
+	}
+	defer func() {
+		s.ctx = prev
+		if s.ctxr != nil {
+			s.ctxr.ctx = prev
+		}
+	}()
+	return s.Decode(val)
+}
+
+// contextError returns a *ContextError if the context of s is done.
+func (s *Stream) contextError() error {
+	if s.ctx == nil {
+		return nil
+	}
+	if err := s.ctx.Err(); err != nil {
+		return &ContextError{err}
+	}
+	return nil
+}
+
+// contextReader is the reader beneath the buffer of a stream created by
+// NewStreamContext.
+type contextReader struct {
+	ctx  context.Context
+	r    io.Reader
+	conn readDeadliner // r, if it supports deadlines
+}
+
+// aLongTimeAgo is a deadline in the past, which interrupts blocked reads.
+var aLongTimeAgo = time.Unix(1, 0)
+
+func (r *contextReader) Read(b []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, &ContextError{err}
+	}
+	if r.conn == nil || r.ctx.Done() == nil {
+		return r.r.Read(b)
+	}
+
+	deadline, _ := r.ctx.Deadline()
+	r.conn.SetReadDeadline(deadline)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-r.ctx.Done():
+			r.conn.SetReadDeadline(aLongTimeAgo)
+		case <-stop:
+		}
+	}()
+	n, err := r.r.Read(b)
+	close(stop)
+	<-done
+	r.conn.SetReadDeadline(time.Time{})
+
+	if err != nil {
+		if cerr := r.ctx.Err(); cerr != nil {
+			err = &ContextError{cerr}
+		} else if !deadline.IsZero() && !time.Now().Before(deadline) {
+			// The read deadline can expire just before the context
+			// notices its own deadline.
+			err = &ContextError{context.DeadlineExceeded}
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// cancelingReader cancels a context when it is first read from.
+type cancelingReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReader) Read(b []byte) (int, error) {
+	r.cancel()
+	return r.r.Read(b)
+}
+
+func TestDecodeContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := NewStream(bytes.NewReader(unhex("C3010203")), 0)
+	var v []uint
+	err := s.DecodeContext(ctx, &v)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("wrong error: %v", err)
+	}
+	var cerr *ContextError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("error is not a *ContextError: %T", err)
+	}
+
+	// The context only applies to the call.
+	if err := s.Decode(&v); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+}
+
+func TestDecodeContextBetweenElements(t *testing.T) {
+	input, _ := EncodeToBytes(make([]uint, 100))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewStream(&cancelingReader{bytes.NewReader(input), cancel}, 0)
+	var v []uint
+	err := s.DecodeContext(ctx, &v)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if err.Error() != "rlp: decoding stopped: context canceled" {
+		t.Fatalf("wrong error message: %q", err)
+	}
+}
+
+func TestNewStreamContextBlockingRead(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		want error
+	}{
+		{
+			name: "cancel",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			want: context.Canceled,
+		},
+		{
+			name: "deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			want: context.DeadlineExceeded,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c1, c2 := net.Pipe()
+			defer c1.Close()
+			defer c2.Close()
+			go c2.Write(unhex("C3")) // the list content never arrives
+
+			ctx, cancel := test.ctx()
+			defer cancel()
+			s := NewStreamContext(ctx, c1, 0)
+			var v []uint
+			done := make(chan error, 1)
+			go func() { done <- s.Decode(&v) }()
+			select {
+			case err := <-done:
+				if !errors.Is(err, test.want) {
+					t.Fatalf("wrong error: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Decode did not return")
+			}
+			// The stream doesn't leave a deadline behind.
+			if err := c1.SetReadDeadline(time.Time{}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNewStreamContextDecodes(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	go c2.Write(unhex("C3010203C20405"))
+
+	s := NewStreamContext(context.Background(), c1, 0)
+	var a, b []uint
+	if err := s.Decode(&a); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.DecodeContext(ctx, &b); err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 3 || len(b) != 2 || b[1] != 5 {
+		t.Fatalf("wrong values %v %v", a, b)
+	}
+}
+
+func TestStreamResetDiscardsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := NewStreamContext(ctx, bytes.NewReader(unhex("01")), 0)
+	s.Reset(bytes.NewReader(unhex("01")), 0)
+	if _, err := s.Uint(); err != nil {
+		t.Fatalf("Reset stream still uses context: %v", err)
+	}
+}
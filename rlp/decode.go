@@ -19,6 +19,7 @@ package rlp
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -47,10 +48,21 @@ var (
 	ErrValueTooLarge    = errors.New("rlp: value size exceeds available input length")
 	ErrMoreThanOneValue = errors.New("rlp: input contains more than one value")
 
+	// These errors are returned when decoding exceeds the Limits
+	// set on a Stream.
+	ErrTooDeep         = errors.New("rlp: maximum list nesting depth exceeded")
+	ErrTooManyElements = errors.New("rlp: maximum number of list elements exceeded")
+	ErrAllocLimit      = errors.New("rlp: maximum decoding allocation exceeded")
+
 	// internal errors
 	errNotInList     = errors.New("rlp: call of ListEnd outside of any list")
 	errNotAtErrEOL   = errors.New("rlp: call of ListEnd not positioned at ErrEOL")
 	errUintOverflow  = errors.New("rlp: uint overflow")
+	errIntOverflow   = errors.New("rlp: int overflow")
+	errCanonSign     = errors.New("rlp: non-canonical sign byte")
+	errCanonFloat    = errors.New("rlp: non-canonical float format")
+	errFloat32Range  = errors.New("rlp: value not representable as float32")
+	errCanonTime     = errors.New("rlp: non-canonical time format")
 	errNoPointer     = errors.New("rlp: interface given to Decode must be a pointer")
 	errDecodeIntoNil = errors.New("rlp: pointer given to Decode must not be nil")
 
@@ -104,44 +116,122 @@ func DecodeBytes(b []byte, val interface{}) error {
 	return nil
 }
 
-type decodeError struct {
-	msg string
-	typ reflect.Type
-	ctx []string
+// DecodeBytesWithLimits is like DecodeBytes, but fails when decoding b
+// exceeds the given limits. Use it for input from untrusted sources.
+func DecodeBytesWithLimits(b []byte, val interface{}, limits Limits) error {
+	r := bytes.NewReader(b)
+
+	stream := streamPool.Get().(*Stream)
+	defer streamPool.Put(stream)
+
+	stream.Reset(r, uint64(len(b)))
+	stream.SetLimits(limits)
+	if err := stream.Decode(val); err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return ErrMoreThanOneValue
+	}
+	return nil
 }
 
-func (err *decodeError) Error() string {
+// DecodeBytesNoCopy is like DecodeBytes, but byte slices and RawValues in the
+// decoded value are subslices of b instead of copies. The caller must not
+// modify b while the decoded value is in use.
+func DecodeBytesNoCopy(b []byte, val interface{}) error {
+	stream := streamPool.Get().(*Stream)
+	defer func() {
+		stream.releaseInput()
+		streamPool.Put(stream)
+	}()
+
+	stream.ResetBytes(b)
+	if err := stream.Decode(val); err != nil {
+		return err
+	}
+	if stream.inputr.Len() > 0 {
+		return ErrMoreThanOneValue
+	}
+	return nil
+}
+
+// DecodeError is returned when the input doesn't fit the Go type it is
+// decoded into. Its message ends with the kind and offset of the input
+// value, like "(List at offset 0x1a3)". Use errors.As to inspect it:
+//
+//	var derr *rlp.DecodeError
+//	if errors.As(err, &derr) {
+//		log.Printf("at offset %#x, field %s: %s", derr.Offset, derr.Field(), derr.Kind)
+//	}
+type DecodeError struct {
+	// Offset is the position in the input of the value that failed to
+	// decode. For errors about the number of elements in a list, it is
+	// the position of the list.
+	Offset uint64
+
+	// Path holds the Go selectors leading from the decoded value to the
+	// one that failed, outermost first, e.g. [".Items", "[4]", ".Price"].
+	// An element in parentheses, like "(pkg.Order)", names the type of a
+	// value passed to Decode.
+	Path []string
+
+	// Kind is the kind of the input value at Offset.
+	Kind Kind
+
+	msg     string
+	typ     reflect.Type
+	located bool // Offset and Kind are set
+}
+
+func (err *DecodeError) Error() string {
 	ctx := ""
-	if len(err.ctx) > 0 {
-		ctx = ", decoding into "
-		for i := len(err.ctx) - 1; i >= 0; i-- {
-			ctx += err.ctx[i]
-		}
+	if len(err.Path) > 0 {
+		ctx = ", decoding into " + err.Field()
+	}
+	loc := ""
+	if err.located {
+		loc = fmt.Sprintf(" (%v at offset %#x)", err.Kind, err.Offset)
 	}
-	return fmt.Sprintf("rlp: %s for %v%s", err.msg, err.typ, ctx)
+	return fmt.Sprintf("rlp: %s for %v%s%s", err.msg, err.typ, ctx, loc)
+}
+
+// Field returns the elements of Path joined into a single selector, like
+// "(pkg.Order).Items[4].Price".
+func (err *DecodeError) Field() string {
+	return strings.Join(err.Path, "")
 }
 
 func wrapStreamError(err error, typ reflect.Type) error {
 	switch err {
 	case ErrCanonInt:
-		return &decodeError{msg: "non-canonical integer (leading zero bytes)", typ: typ}
+		return &DecodeError{msg: "non-canonical integer (leading zero bytes)", typ: typ}
 	case ErrCanonSize:
-		return &decodeError{msg: "non-canonical size information", typ: typ}
+		return &DecodeError{msg: "non-canonical size information", typ: typ}
 	case ErrExpectedList:
-		return &decodeError{msg: "expected input list", typ: typ}
+		return &DecodeError{msg: "expected input list", typ: typ}
 	case ErrExpectedString:
-		return &decodeError{msg: "expected input string or byte", typ: typ}
+		return &DecodeError{msg: "expected input string or byte", typ: typ}
 	case errUintOverflow:
-		return &decodeError{msg: "input string too long", typ: typ}
+		return &DecodeError{msg: "input string too long", typ: typ}
+	case errIntOverflow:
+		return &DecodeError{msg: "integer out of range", typ: typ}
+	case errCanonSign:
+		return &DecodeError{msg: "non-canonical sign byte", typ: typ}
+	case errCanonFloat:
+		return &DecodeError{msg: "non-canonical float (single byte without string header)", typ: typ}
+	case errFloat32Range:
+		return &DecodeError{msg: "value not representable", typ: typ}
+	case errCanonTime:
+		return &DecodeError{msg: "non-canonical time format", typ: typ}
 	case errNotAtErrEOL:
-		return &decodeError{msg: "input list has too many elements", typ: typ}
+		return &DecodeError{msg: "input list has too many elements", typ: typ}
 	}
 	return err
 }
 
 func addErrorContext(err error, ctx string) error {
-	if decErr, ok := err.(*decodeError); ok {
-		decErr.ctx = append(decErr.ctx, ctx)
+	if decErr, ok := err.(*DecodeError); ok {
+		decErr.Path = append([]string{ctx}, decErr.Path...)
 	}
 	return err
 }
@@ -153,6 +243,9 @@ var (
 )
 
 func makeDecoder(typ reflect.Type, tags tags) (dec decoder, err error) {
+	if dec, ok := registeredDecoder(typ); ok {
+		return dec, nil
+	}
 	kind := typ.Kind()
 	switch {
 	case typ == rawValueType:
@@ -169,6 +262,8 @@ func makeDecoder(typ reflect.Type, tags tags) (dec decoder, err error) {
 		return makePtrDecoder(typ, tags)
 	case reflect.PtrTo(typ).Implements(decoderInterface):
 		return decodeDecoder, nil
+	case isBigEndianInteger(typ):
+		return decodeBigEndian, nil
 	case isUint(kind):
 		return decodeUint, nil
 	case isInt(kind):
@@ -183,6 +278,8 @@ func makeDecoder(typ reflect.Type, tags tags) (dec decoder, err error) {
 		return makeListDecoder(typ, tags)
 	case kind == reflect.Struct:
 		return makeStructDecoder(typ)
+	case kind == reflect.Map:
+		return makeMapDecoder(typ)
 	case kind == reflect.Interface:
 		return decodeInterface, nil
 	default:
@@ -270,7 +367,12 @@ func decodeBigInt(s *Stream, val reflect.Value) error {
 }
 
 func decodeTimeTimeNoPtr(s *Stream, val reflect.Value) error {
-	return decodeTimeTime(s, val.Addr())
+	t, err := s.Time()
+	if err != nil {
+		return wrapStreamError(err, val.Type())
+	}
+	val.Set(reflect.ValueOf(t))
+	return nil
 }
 
 func decodeTimeTime(s *Stream, val reflect.Value) error {
@@ -278,19 +380,33 @@ func decodeTimeTime(s *Stream, val reflect.Value) error {
 	if err != nil {
 		return wrapStreamError(err, val.Type())
 	}
-
+	t, err := unmarshalTime(b)
+	if err != nil {
+		return wrapStreamError(err, val.Type())
+	}
 	i := val.Interface().(*time.Time)
-	if i == nil && len(b) > 0 {
+	if i == nil {
 		i = new(time.Time)
 		val.Set(reflect.ValueOf(i))
 	}
-	err = i.UnmarshalBinary(b)
-	if err != nil {
-		return err
-	}
+	*i = t
 	return nil
 }
 
+// unmarshalTime decodes the content of an RLP string holding a time. Only
+// the exact output of time.Time.MarshalBinary for the decoded time is
+// accepted.
+func unmarshalTime(b []byte) (time.Time, error) {
+	var t time.Time
+	if err := t.UnmarshalBinary(b); err != nil {
+		return time.Time{}, err
+	}
+	if enc, err := t.MarshalBinary(); err != nil || !bytes.Equal(enc, b) {
+		return time.Time{}, errCanonTime
+	}
+	return t, nil
+}
+
 func makeListDecoder(typ reflect.Type, tag tags) (decoder, error) {
 	etype := typ.Elem()
 	if etype.Kind() == reflect.Uint8 && !reflect.PtrTo(etype).Implements(decoderInterface) {
@@ -349,6 +465,9 @@ func decodeSliceElems(s *Stream, val reflect.Value, elemdec decoder) error {
 			if newcap < 4 {
 				newcap = 4
 			}
+			if err := s.willAlloc(uint64(newcap-val.Cap()) * uint64(val.Type().Elem().Size())); err != nil {
+				return err
+			}
 			newv := reflect.MakeSlice(val.Type(), val.Len(), newcap)
 			reflect.Copy(newv, val)
 			val.Set(newv)
@@ -383,9 +502,9 @@ func decodeListArray(s *Stream, val reflect.Value, elemdec decoder) error {
 		}
 	}
 	if i < vlen {
-		return &decodeError{msg: "input list has too few elements", typ: val.Type()}
+		return s.listError("input list has too few elements", val.Type())
 	}
-	return wrapStreamError(s.ListEnd(), val.Type())
+	return s.listEnd(val.Type())
 }
 
 func decodeByteSlice(s *Stream, val reflect.Value) error {
@@ -406,19 +525,19 @@ func decodeByteArray(s *Stream, val reflect.Value) error {
 	switch kind {
 	case Byte:
 		if vlen == 0 {
-			return &decodeError{msg: "input string too long", typ: val.Type()}
+			return &DecodeError{msg: "input string too long", typ: val.Type()}
 		}
 		if vlen > 1 {
-			return &decodeError{msg: "input string too short", typ: val.Type()}
+			return &DecodeError{msg: "input string too short", typ: val.Type()}
 		}
 		bv, _ := s.Uint()
 		val.Index(0).SetUint(bv)
 	case String:
 		if uint64(vlen) < size {
-			return &decodeError{msg: "input string too long", typ: val.Type()}
+			return &DecodeError{msg: "input string too long", typ: val.Type()}
 		}
 		if uint64(vlen) > size {
-			return &decodeError{msg: "input string too short", typ: val.Type()}
+			return &DecodeError{msg: "input string too short", typ: val.Type()}
 		}
 		slice := val.Slice(0, vlen).Interface().([]byte)
 		if err := s.readFull(slice); err != nil {
@@ -444,19 +563,124 @@ func makeStructDecoder(typ reflect.Type) (decoder, error) {
 			return nil, structFieldError{typ, f.index, f.info.decoderErr}
 		}
 	}
+	version, versioned := structVersion(typ)
 	dec := func(s *Stream, val reflect.Value) (err error) {
 		if _, err := s.List(); err != nil {
 			return wrapStreamError(err, typ)
 		}
-		for _, f := range fields {
-			err := f.info.decoder(s, val.Field(f.index))
+		if versioned {
+			v, err := s.Uint()
 			if err == ErrEOL {
-				return &decodeError{msg: "too few elements", typ: typ}
+				return s.listError("missing version", typ)
 			} else if err != nil {
-				return addErrorContext(err, "."+typ.Field(f.index).Name)
+				return wrapStreamError(err, typ)
+			}
+			if v != version {
+				return decodeMigrated(s, typ, v, val)
 			}
 		}
-		return wrapStreamError(s.ListEnd(), typ)
+		return decodeStructFields(s, val, typ, fields)
+	}
+	return dec, nil
+}
+
+// decodeStructFields decodes the remaining elements of the current list
+// into the fields of val, then ends the list.
+func decodeStructFields(s *Stream, val reflect.Value, typ reflect.Type, fields []field) error {
+	for i, f := range fields {
+		err := f.info.decoder(s, val.FieldByIndex(f.index))
+		if err == ErrEOL {
+			if f.optional {
+				// The input ends before this optional field, so it
+				// and all remaining fields are set to zero.
+				for _, f := range fields[i:] {
+					fv := val.FieldByIndex(f.index)
+					fv.Set(reflect.Zero(fv.Type()))
+				}
+				break
+			}
+			return s.listError("too few elements", typ)
+		} else if err != nil {
+			return addErrorContext(err, "."+typ.FieldByIndex(f.index).Name)
+		}
+	}
+	return s.listEnd(typ)
+}
+
+// makeMapDecoder creates a decoder for maps. The input must be a list of
+// [key, value] pairs. Any entries already present in the map are discarded.
+func makeMapDecoder(typ reflect.Type) (decoder, error) {
+	if typ.Key().Kind() == reflect.Interface {
+		// decodeInterface produces slices, which can't be used as map keys.
+		return nil, fmt.Errorf("rlp: type %v is not RLP-serializable", typ)
+	}
+	keyinfo := cachedTypeInfo1(typ.Key(), tags{})
+	if keyinfo.decoderErr != nil {
+		return nil, keyinfo.decoderErr
+	}
+	if keyinfo.writerErr != nil {
+		return nil, keyinfo.writerErr
+	}
+	etypeinfo := cachedTypeInfo1(typ.Elem(), tags{})
+	if etypeinfo.decoderErr != nil {
+		return nil, etypeinfo.decoderErr
+	}
+	dec := func(s *Stream, val reflect.Value) error {
+		if _, err := s.List(); err != nil {
+			return wrapStreamError(err, typ)
+		}
+		// The encodings of the keys are compared like the encoder sorts
+		// them, to reject duplicate and unsorted keys.
+		kb := encbufPool.Get().(*encbuf)
+		defer encbufPool.Put(kb)
+		var prevKey []byte
+		m := reflect.MakeMap(typ)
+		for i := 0; ; i++ {
+			if _, err := s.List(); err == ErrEOL {
+				break
+			} else if err != nil {
+				return addErrorContext(wrapStreamError(err, typ), fmt.Sprint("[", i, "]"))
+			}
+			if err := s.willAlloc(uint64(typ.Key().Size() + typ.Elem().Size())); err != nil {
+				return err
+			}
+			key := reflect.New(typ.Key()).Elem()
+			keyKind, _, _ := s.Kind()
+			keyPos := s.kindpos
+			if err := keyinfo.decoder(s, key); err == ErrEOL {
+				return addErrorContext(s.listError("map entry has too few elements", typ), fmt.Sprint("[", i, "]"))
+			} else if err != nil {
+				return addErrorContext(err, fmt.Sprint("[", i, "].key"))
+			}
+			kb.reset()
+			if err := keyinfo.writer(key, kb); err != nil {
+				return err
+			}
+			encKey := kb.toBytes()
+			if i > 0 {
+				if c := bytes.Compare(prevKey, encKey); c >= 0 {
+					msg := "map keys not sorted"
+					if c == 0 {
+						msg = "duplicate map key"
+					}
+					err := &DecodeError{msg: msg, typ: typ, Offset: keyPos, Kind: keyKind, located: true}
+					return addErrorContext(err, fmt.Sprint("[", i, "].key"))
+				}
+			}
+			prevKey = encKey
+			elem := reflect.New(typ.Elem()).Elem()
+			if err := etypeinfo.decoder(s, elem); err == ErrEOL {
+				return addErrorContext(s.listError("map entry has too few elements", typ), fmt.Sprint("[", i, "]"))
+			} else if err != nil {
+				return addErrorContext(err, fmt.Sprint("[", i, "].value"))
+			}
+			if err := s.listEnd(typ); err != nil {
+				return addErrorContext(err, fmt.Sprint("[", i, "]"))
+			}
+			m.SetMapIndex(key, elem)
+		}
+		val.Set(m)
+		return s.listEnd(typ)
 	}
 	return dec, nil
 }
@@ -479,6 +703,9 @@ func makeSimplePtrDecoder(etype reflect.Type, etypeinfo *typeinfo) decoder {
 	return func(s *Stream, val reflect.Value) (err error) {
 		newval := val
 		if val.IsNil() {
+			if err := s.willAlloc(uint64(etype.Size())); err != nil {
+				return err
+			}
 			newval = reflect.New(etype)
 		}
 		if err = etypeinfo.decoder(s, newval.Elem()); err == nil {
@@ -504,7 +731,7 @@ func makeNilPtrDecoder(etype reflect.Type, etypeinfo *typeinfo, nilKind Kind) de
 		// Handle empty values as a nil pointer.
 		if kind != Byte && size == 0 {
 			if kind != nilKind {
-				return &decodeError{
+				return &DecodeError{
 					msg: fmt.Sprintf("wrong kind of empty value (got %v, want %v)", kind, nilKind),
 					typ: typ,
 				}
@@ -518,6 +745,9 @@ func makeNilPtrDecoder(etype reflect.Type, etypeinfo *typeinfo, nilKind Kind) de
 		}
 		newval := val
 		if val.IsNil() {
+			if err := s.willAlloc(uint64(etype.Size())); err != nil {
+				return err
+			}
 			newval = reflect.New(etype)
 		}
 		if err = etypeinfo.decoder(s, newval.Elem()); err == nil {
@@ -609,15 +839,59 @@ type Stream struct {
 	// auxiliary buffer for integer decoding
 	uintbuf []byte
 	intbuf  []byte
+	bigbuf  []byte
+
+	// input is the buffer being decoded in zero-copy mode.
+	// It is nil unless the stream was created by NewByteStream.
+	input  []byte
+	inputr *bytes.Reader
 
 	kind    Kind   // kind of value ahead
 	size    uint64 // size of value ahead
 	byteval byte   // value of single byte in type tag
 	kinderr error  // error from last readKind
 	stack   []listpos
+
+	limits    Limits
+	allocated uint64 // bytes allocated since SetLimits
+
+	// ctx stops decoding when done. ctxr is the reader of streams
+	// created by NewStreamContext.
+	ctx  context.Context
+	ctxr *contextReader
+
+	// pos is the number of bytes read from the input. kindpos and
+	// lastkind are the position and kind of the last value header
+	// read by Kind.
+	pos, kindpos uint64
+	lastkind     Kind
+}
+
+type listpos struct {
+	pos, size uint64
+	start     uint64 // input offset of the list header
+	elems     int    // number of elements read so far
 }
 
-type listpos struct{ pos, size uint64 }
+// Limits bounds the resources used when decoding from a Stream. They
+// protect against input that is small but expands into deeply nested
+// or very large values. A zero field means no limit.
+type Limits struct {
+	// MaxDepth is the maximum nesting depth of lists. A value of 1 permits
+	// a list at toplevel, but no lists inside of it. Exceeding it yields
+	// ErrTooDeep.
+	MaxDepth int
+
+	// MaxAlloc is the maximum number of bytes the decoder may allocate for
+	// strings, slice and map elements and pointer targets. The count is an
+	// estimate that doesn't include runtime overhead. Exceeding it yields
+	// ErrAllocLimit.
+	MaxAlloc uint64
+
+	// MaxListElems is the maximum number of elements in any single list.
+	// Exceeding it yields ErrTooManyElements.
+	MaxListElems int
+}
 
 // NewStream creates a new decoding stream reading from r.
 //
@@ -652,6 +926,16 @@ func NewListStream(r io.Reader, len uint64) *Stream {
 	return s
 }
 
+// NewByteStream creates a new decoding stream reading from b in
+// zero-copy mode. Byte slices returned by Bytes and Raw, and therefore
+// all []byte and RawValue values decoded by the stream, are subslices
+// of b. The input limit is set to the length of b.
+func NewByteStream(b []byte) *Stream {
+	s := new(Stream)
+	s.ResetBytes(b)
+	return s
+}
+
 // Bytes reads an RLP string and returns its contents as a byte slice.
 // If the input does not contain an RLP string, the returned
 // error will be ErrExpectedString.
@@ -663,10 +947,19 @@ func (s *Stream) Bytes() ([]byte, error) {
 	switch kind {
 	case Byte:
 		s.kind = -1 // rearm Kind
+		if s.input != nil {
+			return s.inputSlice(s.inputPos()-1, 1), nil
+		}
 		return []byte{s.byteval}, nil
 	case String:
-		b := make([]byte, size)
-		if err = s.readFull(b); err != nil {
+		var b []byte
+		if s.input != nil {
+			b, err = s.readSlice(size)
+		} else if err = s.willAlloc(size); err == nil {
+			b = make([]byte, size)
+			err = s.readFull(b)
+		}
+		if err != nil {
 			return nil, err
 		}
 		if size == 1 && b[0] < 128 {
@@ -680,18 +973,44 @@ func (s *Stream) Bytes() ([]byte, error) {
 
 // Raw reads a raw encoded value including RLP type information.
 func (s *Stream) Raw() ([]byte, error) {
+	return s.raw(nil)
+}
+
+// raw is like Raw, but reuses the space of buf to hold the value unless
+// the stream is in zero-copy mode.
+func (s *Stream) raw(buf []byte) ([]byte, error) {
 	kind, size, err := s.Kind()
 	if err != nil {
 		return nil, err
 	}
 	if kind == Byte {
 		s.kind = -1 // rearm Kind
-		return []byte{s.byteval}, nil
+		if s.input != nil {
+			return s.inputSlice(s.inputPos()-1, 1), nil
+		}
+		return append(buf[:0], s.byteval), nil
+	}
+	if s.input != nil {
+		// In zero-copy mode, the header is still present in the input
+		// right before the content. Sizes are always canonical, so the
+		// header size can be recomputed from the content size.
+		start := s.inputPos() - headsize(size)
+		if _, err := s.readSlice(size); err != nil {
+			return nil, err
+		}
+		return s.inputSlice(start, headsize(size)+int(size)), nil
 	}
 	// the original header has already been read and is no longer
 	// available. read content and put a new header in front of it.
 	start := headsize(size)
-	buf := make([]byte, uint64(start)+size)
+	if n := uint64(start) + size; uint64(cap(buf)) >= n {
+		buf = buf[:n]
+	} else {
+		if err := s.willAlloc(n); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, n)
+	}
 	if err := s.readFull(buf[start:]); err != nil {
 		return nil, err
 	}
@@ -710,6 +1029,29 @@ func (s *Stream) Uint() (uint64, error) {
 	return s.uint(64)
 }
 
+// Uint64 is an alias for Uint.
+func (s *Stream) Uint64() (uint64, error) {
+	return s.uint(64)
+}
+
+// Uint32 is like Uint, but rejects values that don't fit into 32 bits.
+func (s *Stream) Uint32() (uint32, error) {
+	i, err := s.uint(32)
+	return uint32(i), err
+}
+
+// Uint16 is like Uint, but rejects values that don't fit into 16 bits.
+func (s *Stream) Uint16() (uint16, error) {
+	i, err := s.uint(16)
+	return uint16(i), err
+}
+
+// Uint8 is like Uint, but rejects values that don't fit into 8 bits.
+func (s *Stream) Uint8() (uint8, error) {
+	i, err := s.uint(8)
+	return uint8(i), err
+}
+
 func (s *Stream) uint(maxbits int) (uint64, error) {
 	kind, size, err := s.Kind()
 	if err != nil {
@@ -743,6 +1085,30 @@ func (s *Stream) uint(maxbits int) (uint64, error) {
 	}
 }
 
+// Int64 reads a signed integer in the format written by the encoder for
+// Go int types.
+func (s *Stream) Int64() (int64, error) {
+	return s.int(64)
+}
+
+// Int32 is like Int64, but for 32-bit integers.
+func (s *Stream) Int32() (int32, error) {
+	i, err := s.int(32)
+	return int32(i), err
+}
+
+// Int16 is like Int64, but for 16-bit integers.
+func (s *Stream) Int16() (int16, error) {
+	i, err := s.int(16)
+	return int16(i), err
+}
+
+// Int8 is like Int64, but for 8-bit integers.
+func (s *Stream) Int8() (int8, error) {
+	i, err := s.int(8)
+	return int8(i), err
+}
+
 func (s *Stream) int(maxbits int) (int64, error) {
 	kind, size, err := s.Kind()
 	if err != nil {
@@ -750,16 +1116,14 @@ func (s *Stream) int(maxbits int) (int64, error) {
 	}
 	switch kind {
 	case Byte:
-		if s.byteval == 0 {
-			return 0, ErrCanonInt
-		}
-		s.kind = -1 // rearm Kind
-		return int64(s.byteval), nil
+		// Non-zero signed integers always carry a sign byte, so they
+		// can't be encoded as a single byte.
+		return 0, errCanonSign
 	case String:
 		if size > (uint64(maxbits/8) + 1) {
 			return 0, errUintOverflow
 		}
-		v, err := s.readInt(byte(size))
+		v, err := s.readInt(byte(size), maxbits)
 		switch {
 		case err == ErrCanonSize:
 			// Adjust error because we're not reading a size right now.
@@ -774,29 +1138,58 @@ func (s *Stream) int(maxbits int) (int64, error) {
 	}
 }
 
+// Float64 reads a floating point number in the format written by the
+// encoder for Go float types.
+func (s *Stream) Float64() (float64, error) {
+	return s.float(64)
+}
+
+// Float32 is like Float64, but for 32-bit floating point numbers.
+func (s *Stream) Float32() (float32, error) {
+	f, err := s.float(32)
+	return float32(f), err
+}
+
 func (s *Stream) float(maxbits int) (float64, error) {
+	// Floats are encoded as a string holding their IEEE 754 binary64
+	// bits, regardless of maxbits. The encoder always writes a string
+	// header, so single bytes are not a valid encoding.
 	kind, size, err := s.Kind()
 	if err != nil {
 		return 0, err
 	}
-	switch kind {
-	case String:
-		if size > uint64(maxbits/8) {
-			return 0, errUintOverflow
-		}
-		v, err := s.readFloat(byte(size))
-		switch {
-		case err == ErrCanonSize:
-			// Adjust error because we're not reading a size right now.
-			return 0, ErrCanonInt
-		case err != nil:
-			return 0, err
-		default:
-			return v, nil
-		}
-	default:
+	switch {
+	case kind == Byte:
+		return 0, errCanonFloat
+	case kind != String:
 		return 0, ErrExpectedString
+	case size > 8:
+		return 0, errUintOverflow
+	}
+	var buf [8]byte
+	if err := s.readFull(buf[8-size:]); err != nil {
+		return 0, err
+	}
+	if size > 0 && buf[8-size] == 0 {
+		return 0, ErrCanonInt
+	}
+	bits := binary.BigEndian.Uint64(buf[:])
+	f := math.Float64frombits(bits)
+	if maxbits == 32 && math.Float64bits(float64(float32(f))) != bits {
+		return 0, errFloat32Range
+	}
+	return f, nil
+}
+
+// Time reads an RLP string holding a time in the format written by the
+// encoder for time.Time, which is the output of time.Time.MarshalBinary.
+// Non-canonical encodings are rejected.
+func (s *Stream) Time() (time.Time, error) {
+	b, err := s.Bytes()
+	if err != nil {
+		return time.Time{}, err
 	}
+	return unmarshalTime(b)
 }
 
 // Bool reads an RLP string of up to 1 byte and returns its contents
@@ -817,6 +1210,60 @@ func (s *Stream) Bool() (bool, error) {
 	}
 }
 
+// BigInt decodes an arbitrary-size unsigned integer value.
+func (s *Stream) BigInt() (*big.Int, error) {
+	b, err := s.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	// Reject leading zero bytes
+	if len(b) > 0 && b[0] == 0 {
+		return nil, ErrCanonInt
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// ReadBytes decodes the next RLP value and stores the result in b.
+// The value size must match len(b) exactly.
+func (s *Stream) ReadBytes(b []byte) error {
+	kind, size, err := s.Kind()
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case Byte:
+		if len(b) != 1 {
+			return fmt.Errorf("rlp: input value has wrong size 1, want %d", len(b))
+		}
+		s.kind = -1 // rearm Kind
+		b[0] = s.byteval
+		return nil
+	case String:
+		if uint64(len(b)) != size {
+			return fmt.Errorf("rlp: input value has wrong size %d, want %d", size, len(b))
+		}
+		if err = s.readFull(b); err != nil {
+			return err
+		}
+		if size == 1 && b[0] < 128 {
+			return ErrCanonSize
+		}
+		return nil
+	default:
+		return ErrExpectedString
+	}
+}
+
+// MoreDataInList reports whether the current list context contains
+// more data to be read.
+func (s *Stream) MoreDataInList() bool {
+	if len(s.stack) == 0 {
+		return false
+	}
+	tos := s.stack[len(s.stack)-1]
+	return tos.pos < tos.size
+}
+
 // List starts decoding an RLP list. If the input does not contain a
 // list, the returned error will be ErrExpectedList. When the list's
 // end has been reached, any Stream operation will return ErrEOL.
@@ -828,7 +1275,10 @@ func (s *Stream) List() (size uint64, err error) {
 	if kind != List {
 		return 0, ErrExpectedList
 	}
-	s.stack = append(s.stack, listpos{0, size})
+	if s.limits.MaxDepth > 0 && len(s.stack) >= s.limits.MaxDepth {
+		return 0, ErrTooDeep
+	}
+	s.stack = append(s.stack, listpos{pos: 0, size: size, start: s.kindpos})
 	s.kind = -1
 	s.size = 0
 	return size, nil
@@ -874,16 +1324,41 @@ func (s *Stream) Decode(val interface{}) error {
 	}
 
 	err = decoder(s, rval.Elem())
-	if decErr, ok := err.(*decodeError); ok && len(decErr.ctx) > 0 {
-		// add decode target type to error so context has more meaning
-		decErr.ctx = append(decErr.ctx, fmt.Sprint("(", rtyp.Elem(), ")"))
+	if decErr, ok := err.(*DecodeError); ok {
+		s.locateError(decErr)
+		if len(decErr.Path) > 0 {
+			// add decode target type to error so context has more meaning
+			addErrorContext(decErr, fmt.Sprint("(", rtyp.Elem(), ")"))
+		}
 	}
 	return err
 }
 
+// SetLimits sets the resource limits for decoding from s and restarts
+// the count of allocated bytes. The limits stay in effect until the
+// next call to Reset.
+func (s *Stream) SetLimits(l Limits) {
+	s.limits = l
+	s.allocated = 0
+}
+
+// willAlloc records that the decoder is about to allocate n bytes and
+// reports whether this exceeds the allocation limit.
+func (s *Stream) willAlloc(n uint64) error {
+	if s.limits.MaxAlloc == 0 {
+		return nil
+	}
+	if n > s.limits.MaxAlloc-s.allocated {
+		return ErrAllocLimit
+	}
+	s.allocated += n
+	return nil
+}
+
 // Reset discards any information about the current decoding context
 // and starts reading from r. This method is meant to facilitate reuse
-// of a preallocated Stream across many decoding operations.
+// of a preallocated Stream across many decoding operations. Limits set
+// by SetLimits are cleared.
 //
 // If r does not also implement ByteReader, Stream will do its own
 // buffering.
@@ -911,11 +1386,15 @@ func (s *Stream) Reset(r io.Reader, inputLimit uint64) {
 		bufr = bufio.NewReader(r)
 	}
 	s.r = bufr
+	s.input, s.inputr = nil, nil
 	// Reset the decoding context.
 	s.stack = s.stack[:0]
 	s.size = 0
 	s.kind = -1
 	s.kinderr = nil
+	s.limits, s.allocated = Limits{}, 0
+	s.ctx, s.ctxr = nil, nil
+	s.pos, s.kindpos, s.lastkind = 0, 0, 0
 	if s.uintbuf == nil {
 		s.uintbuf = make([]byte, 8)
 	}
@@ -925,6 +1404,20 @@ func (s *Stream) Reset(r io.Reader, inputLimit uint64) {
 	s.byteval = 0
 }
 
+// ResetBytes is like Reset, but switches the stream to zero-copy
+// mode reading from b. See NewByteStream for details.
+func (s *Stream) ResetBytes(b []byte) {
+	r := bytes.NewReader(b)
+	s.Reset(r, uint64(len(b)))
+	s.input, s.inputr = b, r
+}
+
+// releaseInput drops the references to the input of s, so that a pooled
+// stream doesn't keep it alive or hand it to the next user.
+func (s *Stream) releaseInput() {
+	s.r, s.input, s.inputr = nil, nil, nil
+}
+
 // Kind returns the kind and size of the next value in the
 // input stream.
 //
@@ -948,7 +1441,9 @@ func (s *Stream) Kind() (kind Kind, size uint64, err error) {
 		if tos != nil && tos.pos == tos.size {
 			return 0, 0, ErrEOL
 		}
+		s.kindpos = s.pos
 		s.kind, s.size, s.kinderr = s.readKind()
+		s.lastkind = s.kind
 		if s.kinderr == nil {
 			if tos == nil {
 				// At toplevel, check that the value is smaller
@@ -961,6 +1456,10 @@ func (s *Stream) Kind() (kind Kind, size uint64, err error) {
 				if s.size > tos.size-tos.pos {
 					s.kinderr = ErrElemTooLarge
 				}
+				tos.elems++
+				if s.limits.MaxListElems > 0 && tos.elems > s.limits.MaxListElems {
+					s.kinderr = ErrTooManyElements
+				}
 			}
 		}
 	}
@@ -970,6 +1469,9 @@ func (s *Stream) Kind() (kind Kind, size uint64, err error) {
 }
 
 func (s *Stream) readKind() (kind Kind, size uint64, err error) {
+	if err := s.contextError(); err != nil {
+		return 0, 0, err
+	}
 	b, err := s.readByte()
 	if err != nil {
 		if len(s.stack) == 0 {
@@ -1057,69 +1559,43 @@ func (s *Stream) readUint(size byte) (uint64, error) {
 	}
 }
 
-func (s *Stream) readInt(size byte) (int64, error) {
+func (s *Stream) readInt(size byte, maxbits int) (int64, error) {
 	switch size {
 	case 0:
 		s.kind = -1 // rearm Kind
 		return 0, nil
 	case 1:
-		b, err := s.readByte()
-		return int64(b), err
+		// A sign byte without magnitude.
+		return 0, errCanonSign
 	default:
-		start := int(9 - size)
-		for i := 0; i < start; i++ {
-			s.intbuf[i] = 0
-		}
-		if err := s.readFull(s.intbuf[start:]); err != nil {
+		buf := s.intbuf[:size]
+		if err := s.readFull(buf); err != nil {
 			return 0, err
 		}
-		if s.intbuf[start+1] == 0 {
-			// Note: readUint is also used to decode integer
-			// values. The error needs to be adjusted to become
-			// ErrCanonInt in this case.
+		sign, mag := buf[0], buf[1:]
+		if mag[0] == 0 {
+			// Leading zero bytes, this also rejects negative zero.
+			// The error is adjusted to become ErrCanonInt.
 			return 0, ErrCanonSize
 		}
-
-		in := make([]byte, 8)
-		for i := start; i < 8; i++ {
-			in[i] = s.intbuf[i+1]
+		var v uint64
+		for _, b := range mag {
+			v = v<<8 | uint64(b)
 		}
-
-		switch s.intbuf[start] {
-		case 0:
-			return int64(binary.BigEndian.Uint64(in)), nil
-		case 1:
-			return int64(binary.BigEndian.Uint64(in)) * -1, nil
+		limit := uint64(1) << uint(maxbits-1)
+		switch {
+		case sign == 0 && v < limit:
+			return int64(v), nil
+		case sign == 1 && v <= limit:
+			return -int64(v), nil
+		case sign > 1:
+			return 0, errCanonSign
 		default:
-			return 0, ErrCanonInt
+			return 0, errIntOverflow
 		}
 	}
 }
 
-func (s *Stream) readFloat(size byte) (float64, error) {
-	switch size {
-	case 0:
-		s.kind = -1 // rearm Kind
-		return 0, nil
-	default:
-		start := int(8 - size)
-		for i := 0; i < start; i++ {
-			s.uintbuf[i] = 0
-		}
-		if err := s.readFull(s.uintbuf[start:]); err != nil {
-			return 0, err
-		}
-		if s.uintbuf[start] == 0 {
-			// Note: readUint is also used to decode integer
-			// values. The error needs to be adjusted to become
-			// ErrCanonInt in this case.
-			return 0, ErrCanonSize
-		}
-		bits := binary.BigEndian.Uint64(s.uintbuf)
-		return math.Float64frombits(bits), nil
-	}
-}
-
 func (s *Stream) readFull(buf []byte) (err error) {
 	if err := s.willRead(uint64(len(buf))); err != nil {
 		return err
@@ -1135,6 +1611,32 @@ func (s *Stream) readFull(buf []byte) (err error) {
 	return err
 }
 
+// readSlice is like readFull, but returns the next n bytes of the
+// input as a subslice of s.input instead of copying them. It can only
+// be used in zero-copy mode.
+func (s *Stream) readSlice(n uint64) ([]byte, error) {
+	if err := s.willRead(n); err != nil {
+		return nil, err
+	}
+	if n > uint64(s.inputr.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	start := s.inputPos()
+	s.inputr.Seek(int64(n), io.SeekCurrent)
+	return s.inputSlice(start, int(n)), nil
+}
+
+// inputPos returns the current read position in s.input.
+func (s *Stream) inputPos() int {
+	return len(s.input) - s.inputr.Len()
+}
+
+// inputSlice returns n bytes of s.input starting at start. The capacity
+// of the result is limited so appending to it can't overwrite the input.
+func (s *Stream) inputSlice(start, n int) []byte {
+	return s.input[start : start+n : start+n]
+}
+
 func (s *Stream) readByte() (byte, error) {
 	if err := s.willRead(1); err != nil {
 		return 0, err
@@ -1163,5 +1665,35 @@ func (s *Stream) willRead(n uint64) error {
 		}
 		s.remaining -= n
 	}
+	s.pos += n
 	return nil
 }
+
+// listError returns an error about the innermost list, located at its
+// header. It is used for errors found at the end of a list, when the last
+// value read is not the cause.
+func (s *Stream) listError(msg string, typ reflect.Type) *DecodeError {
+	err := &DecodeError{msg: msg, typ: typ, Kind: List, located: true}
+	if len(s.stack) > 0 {
+		err.Offset = s.stack[len(s.stack)-1].start
+	}
+	return err
+}
+
+// listEnd is like ListEnd, but returns errors as *DecodeError.
+func (s *Stream) listEnd(typ reflect.Type) error {
+	err := s.ListEnd()
+	if err == errNotAtErrEOL {
+		return s.listError("input list has too many elements", typ)
+	}
+	return wrapStreamError(err, typ)
+}
+
+// locateError sets the offset and kind of err to those of the value read
+// last, unless they were set by an inner call to Decode.
+func (s *Stream) locateError(err *DecodeError) {
+	if err.located {
+		return
+	}
+	err.Offset, err.Kind, err.located = s.kindpos, s.lastkind, true
+}
@@ -26,6 +26,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestStreamKind(t *testing.T) {
@@ -255,6 +256,44 @@ func TestStreamList(t *testing.T) {
 	}
 }
 
+func TestStreamSizedReaders(t *testing.T) {
+	s := NewStream(bytes.NewReader(unhex("D08201008401020304820001830102037F")), 0)
+	if _, err := s.List(); err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if _, err := s.Uint8(); err != errUintOverflow {
+		t.Errorf("Uint8 error mismatch, got %v, want %v", err, errUintOverflow)
+	}
+	if v, err := s.Uint16(); err != nil || v != 0x100 {
+		t.Errorf("Uint16 returned %x, %v", v, err)
+	}
+	if v, err := s.Uint32(); err != nil || v != 0x01020304 {
+		t.Errorf("Uint32 returned %x, %v", v, err)
+	}
+	if v, err := s.Int16(); err != nil || v != 1 {
+		t.Errorf("Int16 returned %d, %v", v, err)
+	}
+	var b [3]byte
+	if err := s.ReadBytes(b[:]); err != nil || b != [3]byte{1, 2, 3} {
+		t.Errorf("ReadBytes returned %x, %v", b, err)
+	}
+	if !s.MoreDataInList() {
+		t.Errorf("MoreDataInList returned false before last element")
+	}
+	if err := s.ReadBytes(b[:]); err == nil {
+		t.Errorf("ReadBytes accepted single byte into [3]byte")
+	}
+	if v, err := s.Uint8(); err != nil || v != 0x7F {
+		t.Errorf("Uint8 returned %x, %v", v, err)
+	}
+	if s.MoreDataInList() {
+		t.Errorf("MoreDataInList returned true at end of list")
+	}
+	if err := s.ListEnd(); err != nil {
+		t.Fatalf("ListEnd error: %v", err)
+	}
+}
+
 func TestStreamRaw(t *testing.T) {
 	tests := []struct {
 		input  string
@@ -281,6 +320,16 @@ func TestStreamRaw(t *testing.T) {
 		if !bytes.Equal(want, raw) {
 			t.Errorf("test %d: raw mismatch: got %x, want %x", i, raw, want)
 		}
+
+		s = NewByteStream(unhex(tt.input))
+		s.List()
+		raw, err = s.Raw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(want, raw) {
+			t.Errorf("test %d: zero-copy raw mismatch: got %x, want %x", i, raw, want)
+		}
 	}
 }
 
@@ -357,6 +406,84 @@ type tailPrivateFields struct {
 	x, y bool   //lint:ignore U1000 unused fields required for testing purposes.
 }
 
+type optionalFields struct {
+	A uint
+	B uint `rlp:"optional"`
+	C uint `rlp:"optional"`
+}
+
+type optionalAndTailField struct {
+	A    uint
+	B    uint   `rlp:"optional"`
+	Tail []uint `rlp:"tail"`
+}
+
+type optionalBigIntField struct {
+	A uint
+	B *big.Int `rlp:"optional"`
+}
+
+type optionalPtrField struct {
+	A uint
+	B *[3]byte `rlp:"optional"`
+}
+
+type nonOptionalPtrField struct {
+	A uint
+	B *[3]byte
+}
+
+type invalidOptional1 struct {
+	A uint `rlp:"optional"`
+	B uint
+}
+
+type invalidOptional2 struct {
+	A []uint `rlp:"optional,tail"`
+}
+
+type Audit struct {
+	Created uint
+	Updated uint
+}
+
+type Base struct {
+	ID    uint
+	Audit `rlp:"inline"`
+}
+
+type inlineFields struct {
+	Base `rlp:"inline"`
+	Name string
+}
+
+type embeddedFields struct {
+	Base
+	Name string
+}
+
+type OptionalAudit struct {
+	Updated uint `rlp:"optional"`
+}
+
+type inlineOptional struct {
+	A             uint
+	OptionalAudit `rlp:"inline"`
+}
+
+type TailAudit struct {
+	Tail []uint `rlp:"tail"`
+}
+
+type invalidInline1 struct {
+	A uint `rlp:"inline"`
+}
+
+type invalidInline2 struct {
+	TailAudit `rlp:"inline"`
+	B         uint
+}
+
 type nilListUint struct {
 	X *uint `rlp:"nilList"`
 }
@@ -394,35 +521,35 @@ var decodeTests = []decodeTest{
 	{input: "820505", ptr: new(uint32), value: uint32(0x0505)},
 	{input: "83050505", ptr: new(uint32), value: uint32(0x050505)},
 	{input: "8405050505", ptr: new(uint32), value: uint32(0x05050505)},
-	{input: "850505050505", ptr: new(uint32), error: "rlp: input string too long for uint32"},
-	{input: "C0", ptr: new(uint32), error: "rlp: expected input string or byte for uint32"},
-	{input: "00", ptr: new(uint32), error: "rlp: non-canonical integer (leading zero bytes) for uint32"},
-	{input: "8105", ptr: new(uint32), error: "rlp: non-canonical size information for uint32"},
-	{input: "820004", ptr: new(uint32), error: "rlp: non-canonical integer (leading zero bytes) for uint32"},
-	{input: "B8020004", ptr: new(uint32), error: "rlp: non-canonical size information for uint32"},
+	{input: "850505050505", ptr: new(uint32), error: "rlp: input string too long for uint32 (String at offset 0x0)"},
+	{input: "C0", ptr: new(uint32), error: "rlp: expected input string or byte for uint32 (List at offset 0x0)"},
+	{input: "00", ptr: new(uint32), error: "rlp: non-canonical integer (leading zero bytes) for uint32 (Byte at offset 0x0)"},
+	{input: "8105", ptr: new(uint32), error: "rlp: non-canonical size information for uint32 (String at offset 0x0)"},
+	{input: "820004", ptr: new(uint32), error: "rlp: non-canonical integer (leading zero bytes) for uint32 (String at offset 0x0)"},
+	{input: "B8020004", ptr: new(uint32), error: "rlp: non-canonical size information for uint32 (String at offset 0x0)"},
 
 	// slices
-	{input: "C0", ptr: new([]uint), value: []uint{}},
-	{input: "C80102030405060708", ptr: new([]uint), value: []uint{1, 2, 3, 4, 5, 6, 7, 8}},
-	{input: "F8020004", ptr: new([]uint), error: "rlp: non-canonical size information for []uint"},
+	{input: `[]`, ptr: new([]uint), value: []uint{}},
+	{input: `[1, 2, 3, 4, 5, 6, 7, 8]`, ptr: new([]uint), value: []uint{1, 2, 3, 4, 5, 6, 7, 8}},
+	{input: "F8020004", ptr: new([]uint), error: "rlp: non-canonical size information for []uint (List at offset 0x0)"},
 
 	// arrays
 	{input: "C50102030405", ptr: new([5]uint), value: [5]uint{1, 2, 3, 4, 5}},
-	{input: "C0", ptr: new([5]uint), error: "rlp: input list has too few elements for [5]uint"},
-	{input: "C102", ptr: new([5]uint), error: "rlp: input list has too few elements for [5]uint"},
-	{input: "C6010203040506", ptr: new([5]uint), error: "rlp: input list has too many elements for [5]uint"},
-	{input: "F8020004", ptr: new([5]uint), error: "rlp: non-canonical size information for [5]uint"},
+	{input: "C0", ptr: new([5]uint), error: "rlp: input list has too few elements for [5]uint (List at offset 0x0)"},
+	{input: "C102", ptr: new([5]uint), error: "rlp: input list has too few elements for [5]uint (List at offset 0x0)"},
+	{input: "C6010203040506", ptr: new([5]uint), error: "rlp: input list has too many elements for [5]uint (List at offset 0x0)"},
+	{input: "F8020004", ptr: new([5]uint), error: "rlp: non-canonical size information for [5]uint (List at offset 0x0)"},
 
 	// zero sized arrays
 	{input: "C0", ptr: new([0]uint), value: [0]uint{}},
-	{input: "C101", ptr: new([0]uint), error: "rlp: input list has too many elements for [0]uint"},
+	{input: "C101", ptr: new([0]uint), error: "rlp: input list has too many elements for [0]uint (List at offset 0x0)"},
 
 	// byte slices
 	{input: "01", ptr: new([]byte), value: []byte{1}},
 	{input: "80", ptr: new([]byte), value: []byte{}},
 	{input: "8D6162636465666768696A6B6C6D", ptr: new([]byte), value: []byte("abcdefghijklm")},
-	{input: "C0", ptr: new([]byte), error: "rlp: expected input string or byte for []uint8"},
-	{input: "8105", ptr: new([]byte), error: "rlp: non-canonical size information for []uint8"},
+	{input: "C0", ptr: new([]byte), error: "rlp: expected input string or byte for []uint8 (List at offset 0x0)"},
+	{input: "8105", ptr: new([]byte), error: "rlp: non-canonical size information for []uint8 (String at offset 0x0)"},
 
 	// byte arrays
 	{input: "02", ptr: new([1]byte), value: [1]byte{2}},
@@ -430,149 +557,279 @@ var decodeTests = []decodeTest{
 	{input: "850102030405", ptr: new([5]byte), value: [5]byte{1, 2, 3, 4, 5}},
 
 	// byte array errors
-	{input: "02", ptr: new([5]byte), error: "rlp: input string too short for [5]uint8"},
-	{input: "80", ptr: new([5]byte), error: "rlp: input string too short for [5]uint8"},
-	{input: "820000", ptr: new([5]byte), error: "rlp: input string too short for [5]uint8"},
-	{input: "C0", ptr: new([5]byte), error: "rlp: expected input string or byte for [5]uint8"},
-	{input: "C3010203", ptr: new([5]byte), error: "rlp: expected input string or byte for [5]uint8"},
-	{input: "86010203040506", ptr: new([5]byte), error: "rlp: input string too long for [5]uint8"},
-	{input: "8105", ptr: new([1]byte), error: "rlp: non-canonical size information for [1]uint8"},
-	{input: "817F", ptr: new([1]byte), error: "rlp: non-canonical size information for [1]uint8"},
+	{input: "02", ptr: new([5]byte), error: "rlp: input string too short for [5]uint8 (Byte at offset 0x0)"},
+	{input: "80", ptr: new([5]byte), error: "rlp: input string too short for [5]uint8 (String at offset 0x0)"},
+	{input: "820000", ptr: new([5]byte), error: "rlp: input string too short for [5]uint8 (String at offset 0x0)"},
+	{input: "C0", ptr: new([5]byte), error: "rlp: expected input string or byte for [5]uint8 (List at offset 0x0)"},
+	{input: "C3010203", ptr: new([5]byte), error: "rlp: expected input string or byte for [5]uint8 (List at offset 0x0)"},
+	{input: "86010203040506", ptr: new([5]byte), error: "rlp: input string too long for [5]uint8 (String at offset 0x0)"},
+	{input: "8105", ptr: new([1]byte), error: "rlp: non-canonical size information for [1]uint8 (String at offset 0x0)"},
+	{input: "817F", ptr: new([1]byte), error: "rlp: non-canonical size information for [1]uint8 (String at offset 0x0)"},
 
 	// zero sized byte arrays
 	{input: "80", ptr: new([0]byte), value: [0]byte{}},
-	{input: "01", ptr: new([0]byte), error: "rlp: input string too long for [0]uint8"},
-	{input: "8101", ptr: new([0]byte), error: "rlp: input string too long for [0]uint8"},
+	{input: "01", ptr: new([0]byte), error: "rlp: input string too long for [0]uint8 (Byte at offset 0x0)"},
+	{input: "8101", ptr: new([0]byte), error: "rlp: input string too long for [0]uint8 (String at offset 0x0)"},
 
 	// strings
 	{input: "00", ptr: new(string), value: "\000"},
 	{input: "8D6162636465666768696A6B6C6D", ptr: new(string), value: "abcdefghijklm"},
-	{input: "C0", ptr: new(string), error: "rlp: expected input string or byte for string"},
+	{input: "C0", ptr: new(string), error: "rlp: expected input string or byte for string (List at offset 0x0)"},
 
 	// big ints
 	{input: "01", ptr: new(*big.Int), value: big.NewInt(1)},
 	{input: "89FFFFFFFFFFFFFFFFFF", ptr: new(*big.Int), value: veryBigInt},
 	{input: "10", ptr: new(big.Int), value: *big.NewInt(16)}, // non-pointer also works
-	{input: "C0", ptr: new(*big.Int), error: "rlp: expected input string or byte for *big.Int"},
-	{input: "820001", ptr: new(big.Int), error: "rlp: non-canonical integer (leading zero bytes) for *big.Int"},
-	{input: "8105", ptr: new(big.Int), error: "rlp: non-canonical size information for *big.Int"},
+	{input: "C0", ptr: new(*big.Int), error: "rlp: expected input string or byte for *big.Int (List at offset 0x0)"},
+	{input: "820001", ptr: new(big.Int), error: "rlp: non-canonical integer (leading zero bytes) for *big.Int (String at offset 0x0)"},
+	{input: "8105", ptr: new(big.Int), error: "rlp: non-canonical size information for *big.Int (String at offset 0x0)"},
+
+	// time.Time
+	{input: "80", ptr: new(*time.Time), error: "Time.UnmarshalBinary: no data"},
+	{input: "80", ptr: new(time.Time), error: "Time.UnmarshalBinary: no data"},
 
 	// structs
 	{
-		input: "C50583343434",
+		input: `[5, "444"]`,
 		ptr:   new(simplestruct),
 		value: simplestruct{5, "444"},
 	},
 	{
-		input: "C601C402C203C0",
+		input: `[1, [2, [3, []]]]`,
 		ptr:   new(recstruct),
 		value: recstruct{1, &recstruct{2, &recstruct{3, nil}}},
 	},
 
 	// struct errors
 	{
-		input: "C0",
+		input: `[]`,
 		ptr:   new(simplestruct),
-		error: "rlp: too few elements for rlp.simplestruct",
+		error: "rlp: too few elements for rlp.simplestruct (List at offset 0x0)",
 	},
 	{
-		input: "C105",
+		input: `[5]`,
 		ptr:   new(simplestruct),
-		error: "rlp: too few elements for rlp.simplestruct",
+		error: "rlp: too few elements for rlp.simplestruct (List at offset 0x0)",
 	},
 	{
-		input: "C7C50583343434C0",
+		input: `[[5, "444"], []]`,
 		ptr:   new([]*simplestruct),
-		error: "rlp: too few elements for rlp.simplestruct, decoding into ([]*rlp.simplestruct)[1]",
+		error: "rlp: too few elements for rlp.simplestruct, decoding into ([]*rlp.simplestruct)[1] (List at offset 0x7)",
 	},
 	{
 		input: "83222222",
 		ptr:   new(simplestruct),
-		error: "rlp: expected input list for rlp.simplestruct",
+		error: "rlp: expected input list for rlp.simplestruct (String at offset 0x0)",
 	},
 	{
-		input: "C3010101",
+		input: `[1, 1, 1]`,
 		ptr:   new(simplestruct),
-		error: "rlp: input list has too many elements for rlp.simplestruct",
+		error: "rlp: input list has too many elements for rlp.simplestruct (List at offset 0x0)",
 	},
 	{
-		input: "C501C3C00000",
+		input: `[1, [[], "0x00", "0x00"]]`,
 		ptr:   new(recstruct),
-		error: "rlp: expected input string or byte for uint, decoding into (rlp.recstruct).Child.I",
+		error: "rlp: expected input string or byte for uint, decoding into (rlp.recstruct).Child.I (List at offset 0x3)",
 	},
+	// Signed integers are a sign byte, 0 or 1, followed by the magnitude.
 	{
-		input: "C3820003",
+		input: `["0x0003"]`,
 		ptr:   new(intField),
 		value: intField{3},
 	},
 	{
-		input: "C3820103",
+		input: `["0x0103"]`,
 		ptr:   new(intField),
 		value: intField{-3},
 	},
 	{
-		input: "C50102C20102",
+		input: `[1, 2, [1, 2]]`,
 		ptr:   new(tailUint),
-		error: "rlp: expected input string or byte for uint, decoding into (rlp.tailUint).Tail[1]",
+		error: "rlp: expected input string or byte for uint, decoding into (rlp.tailUint).Tail[1] (List at offset 0x3)",
 	},
 	{
-		input: "C0",
+		input: `[]`,
 		ptr:   new(invalidNilTag),
 		error: `rlp: invalid struct tag "nil" for rlp.invalidNilTag.X (field is not a pointer)`,
 	},
 
 	// struct tag "tail"
 	{
-		input: "C3010203",
+		input: `[1, 2, 3]`,
 		ptr:   new(tailRaw),
 		value: tailRaw{A: 1, Tail: []RawValue{unhex("02"), unhex("03")}},
 	},
 	{
-		input: "C20102",
+		input: `[1, 2]`,
 		ptr:   new(tailRaw),
 		value: tailRaw{A: 1, Tail: []RawValue{unhex("02")}},
 	},
 	{
-		input: "C101",
+		input: `[1]`,
 		ptr:   new(tailRaw),
 		value: tailRaw{A: 1, Tail: []RawValue{}},
 	},
 	{
-		input: "C3010203",
+		input: `[1, 2, 3]`,
 		ptr:   new(tailPrivateFields),
 		value: tailPrivateFields{A: 1, Tail: []uint{2, 3}},
 	},
 	{
-		input: "C0",
+		input: `[]`,
 		ptr:   new(invalidTail1),
 		error: `rlp: invalid struct tag "tail" for rlp.invalidTail1.A (must be on last field)`,
 	},
 	{
-		input: "C0",
+		input: `[]`,
 		ptr:   new(invalidTail2),
 		error: `rlp: invalid struct tag "tail" for rlp.invalidTail2.B (field type is not slice)`,
 	},
 
+	// struct tag "optional"
+	{
+		input: `[1]`,
+		ptr:   new(optionalFields),
+		value: optionalFields{1, 0, 0},
+	},
+	{
+		input: `[1, 2]`,
+		ptr:   new(optionalFields),
+		value: optionalFields{1, 2, 0},
+	},
+	{
+		input: `[1, 2, 3]`,
+		ptr:   new(optionalFields),
+		value: optionalFields{1, 2, 3},
+	},
+	{
+		input: `[1, 2, 3, 4]`,
+		ptr:   new(optionalFields),
+		error: "rlp: input list has too many elements for rlp.optionalFields (List at offset 0x0)",
+	},
+	{
+		input: `[]`,
+		ptr:   new(optionalFields),
+		error: "rlp: too few elements for rlp.optionalFields (List at offset 0x0)",
+	},
+	{
+		// Absent optional fields are reset to zero.
+		input: `[1]`,
+		ptr:   &optionalFields{A: 9, B: 8, C: 7},
+		value: optionalFields{1, 0, 0},
+	},
+	{
+		input: `[1]`,
+		ptr:   new(optionalAndTailField),
+		value: optionalAndTailField{A: 1},
+	},
+	{
+		input: `[1, 2]`,
+		ptr:   new(optionalAndTailField),
+		value: optionalAndTailField{A: 1, B: 2, Tail: []uint{}},
+	},
+	{
+		input: `[1, 2, 3, 4]`,
+		ptr:   new(optionalAndTailField),
+		value: optionalAndTailField{A: 1, B: 2, Tail: []uint{3, 4}},
+	},
+	{
+		input: `[1]`,
+		ptr:   new(optionalBigIntField),
+		value: optionalBigIntField{A: 1, B: nil},
+	},
+	{
+		input: `[1, 2]`,
+		ptr:   new(optionalBigIntField),
+		value: optionalBigIntField{A: 1, B: big.NewInt(2)},
+	},
+	{
+		input: `[1]`,
+		ptr:   new(optionalPtrField),
+		value: optionalPtrField{A: 1},
+	},
+	{
+		input: `[1, ""]`, // not accepted because "optional" doesn't enable "nil"
+		ptr:   new(optionalPtrField),
+		error: "rlp: input string too short for [3]uint8, decoding into (rlp.optionalPtrField).B (String at offset 0x2)",
+	},
+	{
+		input: `[1, "0x010203"]`,
+		ptr:   new(optionalPtrField),
+		value: optionalPtrField{A: 1, B: &[3]byte{1, 2, 3}},
+	},
+	{
+		input: `[]`,
+		ptr:   new(invalidOptional1),
+		error: `rlp: invalid struct tag "" for rlp.invalidOptional1.B (must be optional because preceding field "A" is optional)`,
+	},
+	{
+		input: `[]`,
+		ptr:   new(invalidOptional2),
+		error: `rlp: invalid struct tag "tail" for rlp.invalidOptional2.A (also has "optional" tag)`,
+	},
+
+	// struct tag "inline"
+	{
+		input: `[1, 2, 3, "z"]`,
+		ptr:   new(inlineFields),
+		value: inlineFields{Base{1, Audit{2, 3}}, "z"},
+	},
+	{
+		input: `[1, 2, 3]`,
+		ptr:   new(inlineFields),
+		error: "rlp: too few elements for rlp.inlineFields (List at offset 0x0)",
+	},
+	{
+		input: `[1, [2, 3], "z"]`,
+		ptr:   new(inlineFields),
+		error: "rlp: expected input string or byte for uint, decoding into (rlp.inlineFields).Created (List at offset 0x2)",
+	},
+	{
+		input: `[[1, 2, 3], "z"]`,
+		ptr:   new(embeddedFields),
+		value: embeddedFields{Base{1, Audit{2, 3}}, "z"},
+	},
+	{
+		input: `[1]`,
+		ptr:   new(inlineOptional),
+		value: inlineOptional{A: 1},
+	},
+	{
+		input: `[1, 2]`,
+		ptr:   new(inlineOptional),
+		value: inlineOptional{1, OptionalAudit{2}},
+	},
+	{
+		input: `[]`,
+		ptr:   new(invalidInline1),
+		error: `rlp: invalid struct tag "inline" for rlp.invalidInline1.A (field is not an embedded struct)`,
+	},
+	{
+		input: `[]`,
+		ptr:   new(invalidInline2),
+		error: `rlp: invalid struct tag "tail" for rlp.invalidInline2.Tail (must be on last field)`,
+	},
+
 	// struct tag "-"
 	{
-		input: "C20102",
+		input: `[1, 2]`,
 		ptr:   new(hasIgnoredField),
 		value: hasIgnoredField{A: 1, C: 2},
 	},
 
 	// struct tag "nilList"
 	{
-		input: "C180",
+		input: `[""]`,
 		ptr:   new(nilListUint),
-		error: "rlp: wrong kind of empty value (got String, want List) for *uint, decoding into (rlp.nilListUint).X",
+		error: "rlp: wrong kind of empty value (got String, want List) for *uint, decoding into (rlp.nilListUint).X (String at offset 0x1)",
 	},
 	{
-		input: "C1C0",
+		input: `[[]]`,
 		ptr:   new(nilListUint),
 		value: nilListUint{},
 	},
 	{
-		input: "C103",
+		input: `[3]`,
 		ptr:   new(nilListUint),
 		value: func() interface{} {
 			v := uint(3)
@@ -582,21 +839,50 @@ var decodeTests = []decodeTest{
 
 	// struct tag "nilString"
 	{
-		input: "C1C0",
+		input: `[[]]`,
 		ptr:   new(nilStringSlice),
-		error: "rlp: wrong kind of empty value (got List, want String) for *[]uint, decoding into (rlp.nilStringSlice).X",
+		error: "rlp: wrong kind of empty value (got List, want String) for *[]uint, decoding into (rlp.nilStringSlice).X (List at offset 0x1)",
 	},
 	{
-		input: "C180",
+		input: `[""]`,
 		ptr:   new(nilStringSlice),
 		value: nilStringSlice{},
 	},
 	{
-		input: "C2C103",
+		input: `[[3]]`,
 		ptr:   new(nilStringSlice),
 		value: nilStringSlice{X: &[]uint{3}},
 	},
 
+	// maps
+	{input: `[]`, ptr: new(map[string]uint), value: map[string]uint{}},
+	{input: `[["a", 1], ["b", 2]]`, ptr: new(map[string]uint), value: map[string]uint{"a": 1, "b": 2}},
+	{
+		input: `[["b", 2], ["a", 1]]`,
+		ptr:   new(map[string]uint),
+		error: "rlp: map keys not sorted for map[string]uint, decoding into (map[string]uint)[1].key (Byte at offset 0x5)",
+	},
+	{
+		input: `[["a", 1], ["a", 2]]`,
+		ptr:   new(map[string]uint),
+		error: "rlp: duplicate map key for map[string]uint, decoding into (map[string]uint)[1].key (Byte at offset 0x5)",
+	},
+	{
+		input: `[[[2, 1], "a"], [[1, 2], "b"]]`,
+		ptr:   new(map[[2]uint]string),
+		error: "rlp: map keys not sorted for map[[2]uint]string, decoding into (map[[2]uint]string)[1].key (List at offset 0x7)",
+	},
+	{input: `[["x", [1, 2]]]`, ptr: new(map[string][]uint), value: map[string][]uint{"x": {1, 2}}},
+	{input: `[[[1, 2], "a"], [[2, 1], "b"]]`, ptr: new(map[[2]uint]string), value: map[[2]uint]string{{1, 2}: "a", {2, 1}: "b"}},
+	{input: `[["a", 1]]`, ptr: &map[string]uint{"z": 9}, value: map[string]uint{"a": 1}},
+	{input: "80", ptr: new(map[string]uint), error: "rlp: expected input list for map[string]uint (String at offset 0x0)"},
+	{input: `["a"]`, ptr: new(map[string]uint), error: "rlp: expected input list for map[string]uint, decoding into (map[string]uint)[0] (Byte at offset 0x1)"},
+	{input: `[["a"]]`, ptr: new(map[string]uint), error: "rlp: map entry has too few elements for map[string]uint, decoding into (map[string]uint)[0] (List at offset 0x1)"},
+	{input: `[["a", 1, 2]]`, ptr: new(map[string]uint), error: "rlp: input list has too many elements for map[string]uint, decoding into (map[string]uint)[0] (List at offset 0x1)"},
+	{input: `[[[], 1]]`, ptr: new(map[string]uint), error: "rlp: expected input string or byte for string, decoding into (map[string]uint)[0].key (List at offset 0x2)"},
+	{input: `[["a", []]]`, ptr: new(map[string]uint), error: "rlp: expected input string or byte for uint, decoding into (map[string]uint)[0].value (List at offset 0x3)"},
+	{input: `[]`, ptr: new(map[interface{}]uint), error: "rlp: type map[interface {}]uint is not RLP-serializable"},
+
 	// RawValue
 	{input: "01", ptr: new(RawValue), value: RawValue(unhex("01"))},
 	{input: "82FFFF", ptr: new(RawValue), value: RawValue(unhex("82FFFF"))},
@@ -605,9 +891,9 @@ var decodeTests = []decodeTest{
 	// pointers
 	{input: "00", ptr: new(*[]byte), value: &[]byte{0}},
 	{input: "80", ptr: new(*uint), value: uintp(0)},
-	{input: "C0", ptr: new(*uint), error: "rlp: expected input string or byte for uint"},
+	{input: "C0", ptr: new(*uint), error: "rlp: expected input string or byte for uint (List at offset 0x0)"},
 	{input: "07", ptr: new(*uint), value: uintp(7)},
-	{input: "817F", ptr: new(*uint), error: "rlp: non-canonical size information for uint"},
+	{input: "817F", ptr: new(*uint), error: "rlp: non-canonical size information for uint (String at offset 0x0)"},
 	{input: "8180", ptr: new(*uint), value: uintp(0x80)},
 	{input: "C109", ptr: new(*[]uint), value: &[]uint{9}},
 	{input: "C58403030303", ptr: new(*[][]byte), value: &[][]byte{{3, 3, 3, 3}}},
@@ -640,9 +926,9 @@ func uintp(i uint) *uint { return &i }
 
 func runTests(t *testing.T, decode func([]byte, interface{}) error) {
 	for i, test := range decodeTests {
-		input, err := hex.DecodeString(test.input)
+		input, err := parseFixture(test.input)
 		if err != nil {
-			t.Errorf("test %d: invalid hex input %q", i, test.input)
+			t.Errorf("test %d: invalid input %q: %v", i, test.input, err)
 			continue
 		}
 		err = decode(input, test.ptr)
@@ -664,6 +950,79 @@ func runTests(t *testing.T, decode func([]byte, interface{}) error) {
 	}
 }
 
+type errOrder struct {
+	ID    uint
+	Items []errItem
+}
+
+type errItem struct {
+	Name  string
+	Price uint
+}
+
+func TestDecodeErrorLocation(t *testing.T) {
+	tests := []struct {
+		input  string
+		ptr    interface{}
+		offset uint64
+		path   []string
+		kind   Kind
+	}{
+		{
+			input:  `[1, [["a", 1], ["b", []]]]`,
+			ptr:    new(errOrder),
+			offset: 8,
+			path:   []string{"(rlp.errOrder)", ".Items", "[1]", ".Price"},
+			kind:   List,
+		},
+		{
+			input:  `[1, [["a", 0x010000000000000000]]]`,
+			ptr:    new(errOrder),
+			offset: 5,
+			path:   []string{"(rlp.errOrder)", ".Items", "[0]", ".Price"},
+			kind:   String,
+		},
+		{
+			// Missing elements are reported at the list.
+			input:  `[1, [["a"]]]`,
+			ptr:    new(errOrder),
+			offset: 3,
+			path:   []string{"(rlp.errOrder)", ".Items", "[0]"},
+			kind:   List,
+		},
+		{
+			input:  `[]`,
+			ptr:    new(uint),
+			offset: 0,
+			kind:   List,
+		},
+	}
+	for i, test := range tests {
+		input, err := ParseText(test.input)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		err = DecodeBytes(input, test.ptr)
+		var decErr *DecodeError
+		if !errors.As(fmt.Errorf("wrapped: %w", err), &decErr) {
+			t.Errorf("test %d: error is not a *DecodeError: %v", i, err)
+			continue
+		}
+		if decErr.Offset != test.offset {
+			t.Errorf("test %d: wrong offset %d, want %d", i, decErr.Offset, test.offset)
+		}
+		if !reflect.DeepEqual(decErr.Path, test.path) {
+			t.Errorf("test %d: wrong path %q, want %q", i, decErr.Path, test.path)
+		}
+		if decErr.Kind != test.kind {
+			t.Errorf("test %d: wrong kind %v, want %v", i, decErr.Kind, test.kind)
+		}
+		if msg := fmt.Sprintf("(%v at offset %#x)", test.kind, test.offset); !strings.HasSuffix(err.Error(), msg) {
+			t.Errorf("test %d: error %q does not end with %q", i, err, msg)
+		}
+	}
+}
+
 func TestDecodeWithByteReader(t *testing.T) {
 	runTests(t, func(input []byte, into interface{}) error {
 		return Decode(bytes.NewReader(input), into)
@@ -703,6 +1062,73 @@ func TestDecodeStreamReset(t *testing.T) {
 	})
 }
 
+func TestDecodeBytesNoCopy(t *testing.T) {
+	runTests(t, DecodeBytesNoCopy)
+}
+
+func TestDecodeStreamResetBytes(t *testing.T) {
+	s := NewByteStream(nil)
+	runTests(t, func(input []byte, into interface{}) error {
+		s.ResetBytes(input)
+		return s.Decode(into)
+	})
+}
+
+func TestDecodeBytesNoCopyAliasing(t *testing.T) {
+	var val struct {
+		A []byte
+		B []byte
+		R RawValue
+		I interface{}
+	}
+	input := unhex("CD8361626305C3820102C3828384")
+	if err := DecodeBytesNoCopy(input, &val); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+
+	aliases := func(b []byte, offset int) bool {
+		return len(b) > 0 && &b[0] == &input[offset]
+	}
+	if !aliases(val.A, 2) {
+		t.Errorf("A does not alias input")
+	}
+	if !aliases(val.B, 5) {
+		t.Errorf("B does not alias input")
+	}
+	if !aliases(val.R, 6) || !bytes.Equal(val.R, unhex("C3820102")) {
+		t.Errorf("R does not alias input: %x", val.R)
+	}
+	if v, ok := val.I.([]interface{}); !ok || len(v) != 1 || !aliases(v[0].([]byte), 12) {
+		t.Errorf("I does not alias input: %#v", val.I)
+	}
+
+	// Appending to a decoded slice must not overwrite the input.
+	_ = append(val.A, 0xFF)
+	if !bytes.Equal(input, unhex("CD8361626305C3820102C3828384")) {
+		t.Errorf("input modified by append: %x", input)
+	}
+}
+
+// DecodeBytesNoCopy calls releaseInput before putting its stream back
+// into the pool, so the pool doesn't keep a reference to the input.
+func TestStreamReleaseInput(t *testing.T) {
+	s := new(Stream)
+	s.ResetBytes([]byte{0x05})
+	if _, err := s.Uint(); err != nil {
+		t.Fatal(err)
+	}
+	s.releaseInput()
+	if s.input != nil || s.inputr != nil || s.r != nil {
+		t.Error("stream still holds its input")
+	}
+
+	// The stream is usable again after a reset.
+	s.ResetBytes([]byte{0x06})
+	if v, err := s.Uint(); v != 6 || err != nil {
+		t.Errorf("got %d, %v after reset", v, err)
+	}
+}
+
 type testDecoder struct{ called bool }
 
 func (t *testDecoder) DecodeRLP(s *Stream) error {
@@ -913,6 +1339,46 @@ func BenchmarkDecodeIntSliceReuse(b *testing.B) {
 	}
 }
 
+func BenchmarkDecodeByteSlices(b *testing.B) {
+	enc := encodeTestByteSlices(10000)
+	b.SetBytes(int64(len(enc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var s [][]byte
+	for i := 0; i < b.N; i++ {
+		if err := DecodeBytes(enc, &s); err != nil {
+			b.Fatalf("Decode error: %v", err)
+		}
+	}
+}
+
+func BenchmarkDecodeByteSlicesNoCopy(b *testing.B) {
+	enc := encodeTestByteSlices(10000)
+	b.SetBytes(int64(len(enc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var s [][]byte
+	for i := 0; i < b.N; i++ {
+		if err := DecodeBytesNoCopy(enc, &s); err != nil {
+			b.Fatalf("Decode error: %v", err)
+		}
+	}
+}
+
+func encodeTestByteSlices(n int) []byte {
+	s := make([][]byte, n)
+	for i := range s {
+		s[i] = bytes.Repeat([]byte{byte(i)}, 100)
+	}
+	b, err := EncodeToBytes(s)
+	if err != nil {
+		panic(fmt.Sprintf("encode error: %v", err))
+	}
+	return b
+}
+
 func encodeTestSlice(n uint) []byte {
 	s := make([]uint, n)
 	for i := uint(0); i < n; i++ {
@@ -925,6 +1391,25 @@ func encodeTestSlice(n uint) []byte {
 	return b
 }
 
+// parseFixture returns the bytes of a test input or output. Fixtures
+// starting with '[' or '"' are in the notation of ParseText, all others
+// are hex.
+func parseFixture(s string) ([]byte, error) {
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, `"`) {
+		return ParseText(s)
+	}
+	return hex.DecodeString(s)
+}
+
+// fixture is like parseFixture, but panics for invalid fixtures.
+func fixture(s string) []byte {
+	b, err := parseFixture(s)
+	if err != nil {
+		panic(fmt.Sprintf("invalid fixture %q: %v", s, err))
+	}
+	return b
+}
+
 func unhex(str string) []byte {
 	b, err := hex.DecodeString(strings.Replace(str, " ", "", -1))
 	if err != nil {
@@ -32,6 +32,9 @@ Encoding Rules
 
 Package rlp uses reflection and encodes RLP based on the Go type of the value.
 
+If the type has been registered with RegisterType, the registered TypeWriter is used.
+Registration takes precedence over all rules below.
+
 If the type implements the Encoder interface, Encode calls EncodeRLP. It does not
 call EncodeRLP on nil pointer values.
 
@@ -40,7 +43,8 @@ type, slice or array always encodes as an empty RLP list unless the slice or arr
 elememt type byte. A nil pointer to any other value encodes as the empty string.
 
 Struct values are encoded as an RLP list of all their encoded public fields. Recursive
-struct types are supported.
+struct types are supported. If the struct type implements Versioned, its version number
+is encoded as the first element of the list, see the section on versioning below.
 
 To encode slices and arrays, the elements are encoded as an RLP list of the value's
 elements. Note that arrays and slices with element type uint8 or byte are always encoded
@@ -48,21 +52,39 @@ as an RLP string.
 
 A Go string is encoded as an RLP string.
 
+A map is encoded as an RLP list of two-element lists, one [key, value] pair per map
+entry. The pairs are sorted by the encoded bytes of their keys, which makes the encoding
+of a map independent of Go's map iteration order. A nil map encodes as an empty list.
+
 An unsigned integer value is encoded as an RLP string. Zero always encodes as an empty RLP
-string. big.Int values are treated as integers. Signed integers (int, int8, int16, ...)
-are not supported and will return an error when encoding.
+string. big.Int values are treated as integers. Negative big.Int values cannot be encoded.
+Types implementing BigEndianInteger, such as Uint256, are fixed-width unsigned integers
+and encode exactly like a big.Int of the same value, without allocating.
+
+Signed integers, floating point numbers and time.Time values are encoded as RLP strings in
+the formats described in the section on signed integers, floats and times below.
 
 Boolean values are encoded as the unsigned integers zero (false) and one (true).
 
 An interface value encodes as the value contained in the interface.
 
-Floating point numbers, maps, channels and functions are not supported.
+Channels and functions are not supported.
+
+To write or hash a large value without holding its encoding in memory, use
+EncodeStreaming or EncodeToHash. They encode the value twice, first to compute the sizes
+of all lists and then to write the encoding, so EncodeRLP methods must be deterministic.
+Large byte strings are written through without being copied.
+
+EncodeToBytesParallel encodes the elements of large slices on several goroutines. Its
+output is the same as that of EncodeToBytes.
 
 
 Decoding Rules
 
 Decoding uses the following type-dependent rules:
 
+If the type has been registered with RegisterType, the registered TypeDecoder is called.
+
 If the type implements the Decoder interface, DecodeRLP is called.
 
 To decode into a pointer, the value will be decoded as the element type of the pointer. If
@@ -80,35 +102,200 @@ input elements in order. For byte slices, the input must be an RLP string. Array
 decode similarly, with the additional restriction that the number of input elements (or
 bytes) must match the array's defined length.
 
+To decode into a map, the input must be a list of two-element [key, value] lists. A new
+map is allocated and any entries of an existing map are discarded. The pairs must be sorted
+by the encoded bytes of their keys, as the encoder writes them. Duplicate and unsorted keys
+are rejected. Maps with interface key types cannot be decoded into.
+
+Byte slices and RawValues are normally copied out of the input. DecodeBytesNoCopy and
+streams created by NewByteStream decode them as subslices of the input buffer instead,
+which avoids the copy but requires that the buffer is not modified while the decoded
+value is in use.
+
+Large lists and inputs made of many concatenated values don't have to be decoded at once.
+NewListIterator walks the elements of an encoded list without allocating, and Stream.Iter
+reads the elements of the current list, or the remaining top-level values, one at a time.
+
 To decode into a Go string, the input must be an RLP string. The input bytes are taken
 as-is and will not necessarily be valid UTF-8.
 
 To decode into an unsigned integer type, the input must also be an RLP string. The bytes
 are interpreted as a big endian representation of the integer. If the RLP string is larger
 than the bit size of the type, decoding will return an error. Decode also supports
-*big.Int. There is no size limit for big integers.
+*big.Int. There is no size limit for big integers. Values that don't fit into a
+BigEndianInteger type are rejected.
 
 To decode into a boolean, the input must contain an unsigned integer of value zero (false)
 or one (true).
 
+To decode into a signed integer, floating point or time.Time value, the input must be an RLP
+string in the canonical format described below. Non-canonical input is rejected.
+
 To decode into an interface value, one of these types is stored in the value:
 
 	  []interface{}, for RLP lists
 	  []byte, for RLP strings
 
 Non-empty interface types are not supported when decoding.
-Signed integers, floating point numbers, maps, channels and functions cannot be decoded into.
+Channels and functions cannot be decoded into.
+
+Decoding into an interface value loses the signed integer, floating point and time.Time
+types. When the Go type of the encoded value isn't available, DecodeWithSchema can decode
+it according to a Schema that names the types of its elements instead.
+
+
+Decoding Untrusted Input
+
+The input limit of a Stream bounds the size of the input, but a small input can still
+describe deeply nested lists or values that expand into large allocations. For input from
+untrusted sources, set Limits on the Stream with SetLimits or use DecodeBytesWithLimits.
+Limits bound the nesting depth of lists, the number of elements in any single list and
+the number of bytes allocated while decoding. Exceeding them fails decoding with
+ErrTooDeep, ErrTooManyElements or ErrAllocLimit respectively.
+
+Reading from a slow peer can block for a long time. Stream.DecodeContext stops decoding
+between values once its context is done, and streams created by NewStreamContext also
+interrupt blocked reads on readers with a SetReadDeadline method, such as net.Conn. In
+both cases the error is a *ContextError wrapping the error of the context.
+
+
+Signed Integers, Floats and Times
+
+These encodings are not part of the RLP specification. They are fixed as described here so
+that encoded bytes, and hashes computed over them, stay stable across versions. Each value
+has exactly one valid encoding, and decoding rejects every other input.
+
+A signed integer (int, int8, int16, int32, int64) is encoded as follows:
+
+  - Zero encodes as the empty string 0x80.
+  - Any other value encodes as a string of at least two bytes: a sign byte, 0x00 for
+    positive and 0x01 for negative values, followed by the absolute value as a big endian
+    integer without leading zero bytes.
+
+Decoding rejects single bytes and one-byte strings, sign bytes other than 0x00 and 0x01,
+magnitudes with leading zero bytes (including negative zero, 0x820100) and values that do
+not fit the target type. The range is that of two's complement integers, so for int8 the
+input 0x820180 (-128) is accepted and 0x820080 (128) is rejected.
+
+    0         => 0x80
+    1         => 0x820001
+    -1        => 0x820101
+    127       => 0x82007F
+    -128      => 0x820180
+    256       => 0x83000100
+    MinInt64  => 0x89018000000000000000
+
+A floating point number (float32, float64) is encoded as a string holding the IEEE 754
+binary64 representation of the value as a big endian integer without leading zero bytes.
+float32 values are converted to float64 first. This is the format of earlier versions,
+and it is kept so that existing encodings and hashes over them don't change. Zero encodes
+as the empty string. Unlike unsigned integers, every other value is written with a string
+header, even when its representation is a single byte below 0x80. The bits are encoded as
+they are: negative zero and NaN payloads are preserved. Decoding rejects single bytes
+without a string header and leading zero bytes, so each bit pattern has exactly one valid
+encoding. When decoding into float32, the bits must be those of a float32 value converted
+to float64.
+
+    0         => 0x80
+    -0        => 0x888000000000000000
+    1         => 0x883FF0000000000000
+    -2.5      => 0x88C004000000000000
+    +Inf      => 0x887FF0000000000000
+    NaN       => 0x887FF8000000000001
+    5e-324    => 0x8101
+
+A time.Time is encoded as a string containing the output of its MarshalBinary method, which
+holds the wall clock time and the zone offset in minutes (-1 for UTC). The monotonic clock
+reading and the location name are not encoded. Decoding accepts only input that
+MarshalBinary produces for the decoded time. A nil *time.Time encodes as the empty string,
+which is not a valid time and cannot be decoded.
+
+
+Versioning
+
+Encoded structs may outlive the layout that produced them. A struct type that implements
+the Versioned interface is encoded with its version number as the first list element.
+Decoding a value of the current version works as usual. For older versions, a migration
+function must be registered with RegisterMigration. It receives the fields decoded into a
+struct type describing the old layout and fills in the current one:
+
+    type Account struct {
+        Name    string
+        Balance *big.Int
+    }
+
+    func (Account) RLPVersion() uint { return 2 }
+
+    type accountV1 struct {
+        Name    string
+        Balance uint64
+    }
+
+    func init() {
+        rlp.RegisterMigration(1, func(old *accountV1, cur *Account) error {
+            cur.Name = old.Name
+            cur.Balance = new(big.Int).SetUint64(old.Balance)
+            return nil
+        })
+    }
+
+Values are always encoded with the current version.
+
+
+Code Generation
+
+The reflection-based codec can be bypassed for struct types by generating EncodeRLP and
+DecodeRLP methods with the rlpgen command in cmd/rlpgen. The generated code follows the
+rules described in this document, including the struct tags below, and writes through
+EncoderBuffer.
+
+    //go:generate go run github.com/Yamiyo/common/cmd/rlpgen -type MyStruct -out mystruct_rlp.go
 
 
 Struct Tags
 
-Package rlp honours certain struct tags: "-", "tail", "nil", "nilList" and "nilString".
+Package rlp honours certain struct tags: "-", "tail", "optional", "inline", "nil",
+"nilList" and "nilString".
 
 The "-" tag ignores fields.
 
 The "tail" tag, which may only be used on the last exported struct field, allows slurping
 up any excess list elements into a slice. See examples for more details.
 
+The "optional" tag says that the field may be omitted if it is zero-valued. If this tag is
+used on a struct field, all subsequent public struct fields must also be declared optional
+(or use the "tail" tag).
+
+When encoding a struct with optional fields, the output RLP list contains all values up to
+the last non-zero optional field.
+
+When decoding into a struct, optional fields may be omitted from the end of the input
+list. For the example below, this means input lists of one, two, or three elements are
+accepted. Fields missing from the input are set to their zero value.
+
+    type StructWithOptionalFields struct {
+        Required  uint64
+        Optional1 uint64 `rlp:"optional"`
+        Optional2 uint64 `rlp:"optional"`
+    }
+
+The "inline" tag applies to exported embedded struct fields. Without it, an embedded
+struct is encoded as a nested list like any other struct field. With it, the exported
+fields of the embedded struct take its place in the enclosing struct, in declaration order.
+Unexported fields are skipped as usual. Embedded structs within the inlined struct are only
+flattened if they carry an "inline" tag of their own. The struct tags of the inlined fields
+keep their meaning, so a "tail" field in an inlined struct must be the last encoded field.
+
+    type Audit struct {
+        CreatedAt uint64
+        UpdatedAt uint64
+    }
+
+    type Account struct {
+        Audit   `rlp:"inline"`
+        Balance uint64 // encoded as [CreatedAt, UpdatedAt, Balance]
+    }
+
 The "nil" tag applies to pointer-typed fields and changes the decoding rules for the field
 such that input values of size zero decode as a nil pointer. This tag can be useful when
 decoding recursive types.
@@ -0,0 +1,129 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Dump renders the RLP values in b as an indented tree, one value per line.
+// Each line shows the kind of the value, the offset of its header within b
+// and the size of its content. Strings are shown in hex, followed by a
+// quoted form if they consist of printable ASCII characters.
+//
+// For example, the encoding of []interface{}{"cat", uint(1)} dumps as
+//
+//	list @0 size=5
+//	  string @1 size=3 0x636174 "cat"
+//	  byte @5 0x01
+//
+// b may contain more than one value. If b is not valid RLP, Dump returns the
+// output rendered so far along with an error that mentions the offset of the
+// invalid value.
+func Dump(b []byte) (string, error) {
+	var out strings.Builder
+	err := dumpValues(&out, b, 0, 0)
+	return out.String(), err
+}
+
+func dumpValues(out *strings.Builder, b []byte, offset, depth int) error {
+	for len(b) > 0 {
+		k, tagsize, size, err := readKind(b)
+		if err != nil {
+			return fmt.Errorf("%w (offset %d)", err, offset)
+		}
+		content := b[tagsize : tagsize+size]
+		out.WriteString(strings.Repeat("  ", depth))
+		switch k {
+		case Byte:
+			fmt.Fprintf(out, "byte @%d 0x%x\n", offset, content)
+		case String:
+			fmt.Fprintf(out, "string @%d size=%d 0x%x", offset, size, content)
+			if isPrintable(content) {
+				fmt.Fprintf(out, " %q", content)
+			}
+			out.WriteByte('\n')
+		case List:
+			fmt.Fprintf(out, "list @%d size=%d\n", offset, size)
+			if err := dumpValues(out, content, offset+int(tagsize), depth+1); err != nil {
+				return err
+			}
+		}
+		b = b[tagsize+size:]
+		offset += int(tagsize + size)
+	}
+	return nil
+}
+
+func isPrintable(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if c < 0x20 || c > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+// ToJSON converts a single RLP value to JSON. Lists become JSON arrays and
+// strings (including single bytes) become hex strings with a 0x prefix.
+//
+// For example, the encoding of []interface{}{"cat", []uint{}} converts to
+//
+//	["0x636174",[]]
+//
+// ToJSON returns ErrMoreThanOneValue if b contains data after the value.
+func ToJSON(b []byte) ([]byte, error) {
+	var out bytes.Buffer
+	rest, err := jsonValue(&out, b)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, ErrMoreThanOneValue
+	}
+	return out.Bytes(), nil
+}
+
+func jsonValue(out *bytes.Buffer, b []byte) (rest []byte, err error) {
+	k, content, rest, err := Split(b)
+	if err != nil {
+		return b, err
+	}
+	if k != List {
+		out.WriteString(`"0x`)
+		out.WriteString(hex.EncodeToString(content))
+		out.WriteByte('"')
+		return rest, nil
+	}
+	out.WriteByte('[')
+	for i := 0; len(content) > 0; i++ {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if content, err = jsonValue(out, content); err != nil {
+			return b, err
+		}
+	}
+	out.WriteByte(']')
+	return rest, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	tests := []struct {
+		input, output, err string
+	}{
+		{input: "", output: ""},
+		{input: "01", output: "byte @0 0x01\n"},
+		{input: "80", output: "string @0 size=0 0x\n"},
+		{input: "C0", output: "list @0 size=0\n"},
+		{
+			input: "C58363617401",
+			output: "list @0 size=5\n" +
+				"  string @1 size=3 0x636174 \"cat\"\n" +
+				"  byte @5 0x01\n",
+		},
+		{
+			input: "C7C0C1C0C3C0C1C0",
+			output: "list @0 size=7\n" +
+				"  list @1 size=0\n" +
+				"  list @2 size=1\n" +
+				"    list @3 size=0\n" +
+				"  list @4 size=3\n" +
+				"    list @5 size=0\n" +
+				"    list @6 size=1\n" +
+				"      list @7 size=0\n",
+		},
+		{
+			input:  "820AFF0102",
+			output: "string @0 size=2 0x0aff\nbyte @3 0x01\nbyte @4 0x02\n",
+		},
+		{
+			input: "B838" + strings.Repeat("61", 56),
+			output: "string @0 size=56 0x" + strings.Repeat("61", 56) +
+				" \"" + strings.Repeat("a", 56) + "\"\n",
+		},
+		// Errors include the offset of the invalid value, and the output
+		// rendered before the error is returned.
+		{
+			input:  "C3018105",
+			output: "list @0 size=3\n  byte @1 0x01\n",
+			err:    "rlp: non-canonical size information (offset 2)",
+		},
+		{
+			input:  "01C5",
+			output: "byte @0 0x01\n",
+			err:    "rlp: value size exceeds available input length (offset 1)",
+		},
+	}
+	for i, test := range tests {
+		out, err := Dump(unhex(test.input))
+		if out != test.output {
+			t.Errorf("test %d: output mismatch\ngot:\n%s\nwant:\n%s", i, out, test.output)
+		}
+		if err == nil && test.err != "" {
+			t.Errorf("test %d: expected error %q", i, test.err)
+		} else if err != nil && fmt.Sprint(err) != test.err {
+			t.Errorf("test %d: error mismatch: got %q, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		input, output string
+		err           error
+	}{
+		{input: "01", output: `"0x01"`},
+		{input: "80", output: `"0x"`},
+		{input: "C0", output: `[]`},
+		{input: "C58363617401", output: `["0x636174","0x01"]`},
+		{input: "C7C0C1C0C3C0C1C0", output: `[[],[[]],[[],[[]]]]`},
+		{input: "C6836361748180", output: `["0x636174","0x80"]`},
+		{input: "", err: io.ErrUnexpectedEOF},
+		{input: "0102", err: ErrMoreThanOneValue},
+		{input: "C28105", err: ErrCanonSize},
+		{input: "C3010203C0", err: ErrMoreThanOneValue},
+		{input: "C401", err: ErrValueTooLarge},
+	}
+	for i, test := range tests {
+		out, err := ToJSON(unhex(test.input))
+		if err != test.err {
+			t.Errorf("test %d: error mismatch: got %v, want %v", i, err, test.err)
+			continue
+		}
+		if string(out) != test.output {
+			t.Errorf("test %d: output mismatch: got %s, want %s", i, out, test.output)
+		}
+	}
+}
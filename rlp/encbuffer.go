@@ -0,0 +1,152 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"io"
+	"math/big"
+	"time"
+)
+
+// EncoderBuffer is a buffer for incremental encoding. It is used by code
+// generated by rlpgen and can also be used when implementing EncodeRLP
+// by hand.
+//
+// The zero value is NOT ready for use. To get a usable buffer,
+// create it using NewEncoderBuffer.
+type EncoderBuffer struct {
+	buf       *encbuf
+	dst       io.Writer
+	ownBuffer bool
+}
+
+// NewEncoderBuffer creates an encoder buffer. If dst is the writer passed
+// to EncodeRLP, the buffer writes into the outer encoding directly.
+func NewEncoderBuffer(dst io.Writer) EncoderBuffer {
+	var w EncoderBuffer
+	if outer := encbufFromWriter(dst); outer != nil {
+		w.buf = outer
+	} else {
+		w.buf = encbufPool.Get().(*encbuf)
+		w.buf.reset()
+		w.dst = dst
+		w.ownBuffer = true
+	}
+	return w
+}
+
+// encbufFromWriter returns the encbuf underlying w, or nil if w
+// doesn't write to an encbuf.
+func encbufFromWriter(w io.Writer) *encbuf {
+	switch w := w.(type) {
+	case *encbuf:
+		return w
+	case EncoderBuffer:
+		return w.buf
+	case *EncoderBuffer:
+		return w.buf
+	default:
+		return nil
+	}
+}
+
+// Flush writes encoded RLP data to the output writer. This can only be called once.
+// The buffer must not be used after Flush.
+func (w *EncoderBuffer) Flush() error {
+	var err error
+	if w.dst != nil {
+		err = w.buf.toWriter(w.dst)
+	}
+	// Release the internal buffer.
+	if w.ownBuffer {
+		encbufPool.Put(w.buf)
+	}
+	*w = EncoderBuffer{}
+	return err
+}
+
+// ToBytes returns the encoded bytes.
+func (w *EncoderBuffer) ToBytes() []byte {
+	return w.buf.toBytes()
+}
+
+// Write appends b directly to the encoder output.
+func (w EncoderBuffer) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+// List starts a list. It returns an internal index. Call ListEnd with
+// this index after encoding the content to finish the list.
+func (w EncoderBuffer) List() int {
+	w.buf.list()
+	return len(w.buf.lheads) - 1
+}
+
+// ListEnd finishes the given list.
+func (w EncoderBuffer) ListEnd(index int) {
+	w.buf.listEnd(w.buf.lheads[index])
+}
+
+// WriteBool writes b as the integer 0 (false) or 1 (true).
+func (w EncoderBuffer) WriteBool(b bool) {
+	w.buf.writeBool(b)
+}
+
+// WriteUint64 encodes an unsigned integer.
+func (w EncoderBuffer) WriteUint64(i uint64) {
+	w.buf.writeUint64(i)
+}
+
+// WriteInt64 encodes a signed integer.
+func (w EncoderBuffer) WriteInt64(i int64) {
+	w.buf.writeInt64(i)
+}
+
+// WriteFloat64 encodes a floating point number.
+func (w EncoderBuffer) WriteFloat64(f float64) {
+	w.buf.writeFloat64(f)
+}
+
+// WriteBigInt encodes a big.Int as an RLP string. A nil pointer
+// encodes as the empty string. Negative numbers are not supported.
+func (w EncoderBuffer) WriteBigInt(i *big.Int) error {
+	if i == nil {
+		w.buf.str = append(w.buf.str, 0x80)
+		return nil
+	}
+	return writeBigInt(i, w.buf)
+}
+
+// WriteBigEndian encodes a fixed-width unsigned integer like a big.Int.
+func (w EncoderBuffer) WriteBigEndian(i BigEndianInteger) {
+	w.buf.writeBigEndian(i)
+}
+
+// WriteTime encodes t as an RLP string containing its binary marshaling.
+func (w EncoderBuffer) WriteTime(t time.Time) error {
+	return w.buf.writeTime(t)
+}
+
+// WriteBytes encodes b as an RLP string.
+func (w EncoderBuffer) WriteBytes(b []byte) {
+	w.buf.encodeString(b)
+}
+
+// WriteString encodes s as an RLP string.
+func (w EncoderBuffer) WriteString(s string) {
+	w.buf.writeString(s)
+}
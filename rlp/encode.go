@@ -17,11 +17,16 @@
 package rlp
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"math"
 	"math/big"
 	"reflect"
+	"runtime"
+	"sort"
 	"sync"
 	"time"
 )
@@ -54,7 +59,7 @@ type Encoder interface {
 //
 // Please see package-level documentation of encoding rules.
 func Encode(w io.Writer, val interface{}) error {
-	if outer, ok := w.(*encbuf); ok {
+	if outer := encbufFromWriter(w); outer != nil {
 		// Encode was called by some type's EncodeRLP.
 		// Avoid copying by writing to the outer encbuf directly.
 		return outer.encode(val)
@@ -80,6 +85,107 @@ func EncodeToBytes(val interface{}) ([]byte, error) {
 	return eb.toBytes(), nil
 }
 
+// EncodeToBytesParallel is like EncodeToBytes, but encodes the elements of
+// large slices and arrays on up to workers goroutines. If workers is zero
+// or negative, runtime.GOMAXPROCS(0) is used. The output is identical to
+// that of EncodeToBytes.
+//
+// Only slices with at least 1024 elements are split, and
+// slices nested in the elements of a split slice are encoded serially.
+// Since elements are encoded concurrently, their EncodeRLP methods and
+// registered TypeWriters must be safe for concurrent use. If encoding
+// fails, the error of the first failing element is returned.
+func EncodeToBytesParallel(val interface{}, workers int) ([]byte, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	eb := encbufPool.Get().(*encbuf)
+	defer encbufPool.Put(eb)
+	eb.reset()
+	eb.workers = workers
+	if err := eb.encode(val); err != nil {
+		return nil, err
+	}
+	return eb.toBytes(), nil
+}
+
+// HashableEncode writes the RLP encoding of val to w for use as the input
+// of a hash function.
+//
+// The encoding keeps the structure of val: slices, arrays and structs are
+// encoded as lists and maps as lists of [key, value] pairs sorted by key,
+// so values of different shape can't produce the same bytes. This is
+// unlike concatenating the encodings of the leaf values, where
+// [][]string{{"a"}, {"b"}} and []string{"a", "b"} are indistinguishable.
+//
+// The output is the same as that of Encode. In addition, HashableEncode
+// verifies that the output is a single well-formed RLP value, so that
+// RawValues and EncodeRLP methods can't break the structure.
+func HashableEncode(w io.Writer, val interface{}) error {
+	eb := encbufPool.Get().(*encbuf)
+	defer encbufPool.Put(eb)
+	eb.reset()
+	if err := eb.encode(val); err != nil {
+		return err
+	}
+	b := eb.toBytes()
+	rest, err := checkValue(b)
+	if err == nil && len(rest) > 0 {
+		err = ErrMoreThanOneValue
+	}
+	if err != nil {
+		return fmt.Errorf("rlp: invalid encoding of %T for hashing: %v", val, err)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// checkValue verifies that b starts with a well-formed RLP value,
+// including the content of lists, and returns the bytes after it.
+func checkValue(b []byte) (rest []byte, err error) {
+	k, content, rest, err := Split(b)
+	if err != nil {
+		return b, err
+	}
+	if k == List {
+		for len(content) > 0 {
+			if content, err = checkValue(content); err != nil {
+				return b, err
+			}
+		}
+	}
+	return rest, nil
+}
+
+// EncodeToHash writes the RLP encoding of val into h. It works like
+// EncodeStreaming. Use it to hash large values.
+func EncodeToHash(h hash.Hash, val interface{}) error {
+	return EncodeStreaming(h, val)
+}
+
+// EncodeStreaming writes the RLP encoding of val to w. The output is the
+// same as that of Encode, but the encoding is never held in memory as a
+// whole: a first pass over val computes the sizes of all lists, and a
+// second pass writes the encoding to w piece by piece, with strings of
+// 4096 bytes or more passed through directly. Use it for values that hold
+// large byte slices or strings, which Encode would copy into its buffer.
+//
+// Because val is encoded twice, EncodeRLP methods must produce the same
+// output each time they are called. EncodeStreaming returns an error if
+// the passes don't match. If that happens or writing to w fails, part of
+// the encoding may already have been written.
+func EncodeStreaming(w io.Writer, val interface{}) error {
+	if outer := encbufFromWriter(w); outer != nil {
+		// Called by some type's EncodeRLP, the outer encbuf
+		// decides how the output is buffered.
+		return outer.encode(val)
+	}
+	eb := encbufPool.Get().(*encbuf)
+	defer encbufPool.Put(eb)
+	eb.reset()
+	return eb.encodeStreaming(w, val)
+}
+
 // EncodeToReader returns a reader from which the RLP encoding of val
 // can be read. The returned size is the total size of the encoded
 // data.
@@ -100,8 +206,41 @@ type encbuf struct {
 	lhsize   int         // sum of sizes of all encoded list headers
 	sizebuf  []byte      // 9-byte auxiliary buffer for uint encoding
 	nsizebuf []byte      // 10-byte auxiliary buffer for int encoding
+	bigbuf   []byte      // auxiliary buffer for BigEndianInteger encoding
+
+	// workers is the number of goroutines for encoding large slices.
+	// It is set by EncodeToBytesParallel.
+	workers int
+
+	// These fields are used by encodeStreaming.
+	mode      encMode
+	discarded int       // size of string data dropped in the sizing pass
+	nplan     int       // number of list headers computed by the sizing pass
+	out       io.Writer // destination of the streaming pass
+	written   int       // number of bytes written to out
+	outerr    error     // first error of the streaming pass
 }
 
+// encMode is the mode of an encbuf.
+type encMode uint8
+
+const (
+	// encBuffer keeps the whole encoding in memory.
+	encBuffer encMode = iota
+	// encSizing computes list sizes, but drops string data once it has
+	// been counted.
+	encSizing
+	// encStreaming writes string data to out as it is produced. List
+	// headers are taken from the preceding sizing pass.
+	encStreaming
+)
+
+// streamChunkSize is the amount of string data buffered by an encbuf in
+// streaming mode. Larger strings bypass the buffer.
+const streamChunkSize = 4096
+
+var errEncodingChanged = errors.New("rlp: encoding of value changed between passes")
+
 type listhead struct {
 	offset int // index of this header in string data
 	size   int // total size of encoded data (including list headers)
@@ -143,6 +282,9 @@ var encbufPool = sync.Pool{
 
 func (w *encbuf) reset() {
 	w.lhsize = 0
+	w.workers = 0
+	w.mode, w.discarded, w.nplan = encBuffer, 0, 0
+	w.out, w.written, w.outerr = nil, 0, nil
 	if w.str != nil {
 		w.str = w.str[:0]
 	}
@@ -153,10 +295,105 @@ func (w *encbuf) reset() {
 
 // encbuf implements io.Writer so it can be passed it into EncodeRLP.
 func (w *encbuf) Write(b []byte) (int, error) {
-	w.str = append(w.str, b...)
+	w.writeContent(b)
 	return len(b), nil
 }
 
+// writeContent appends b to the string data. In the streaming modes, large
+// inputs are counted or written out directly instead of being buffered.
+func (w *encbuf) writeContent(b []byte) {
+	if w.mode == encBuffer || len(b) < streamChunkSize {
+		w.str = append(w.str, b...)
+		w.spill()
+		return
+	}
+	if w.mode == encSizing {
+		w.discarded += len(w.str) + len(b)
+	} else {
+		w.writeOut(w.str)
+		w.writeOut(b)
+	}
+	w.str = w.str[:0]
+}
+
+// writeStringContent is like writeContent, but avoids copying s when it
+// is written out directly.
+func (w *encbuf) writeStringContent(s string) {
+	if w.mode == encBuffer || len(s) < streamChunkSize {
+		w.str = append(w.str, s...)
+		w.spill()
+		return
+	}
+	if w.mode == encSizing {
+		w.discarded += len(w.str) + len(s)
+	} else {
+		w.writeOut(w.str)
+		if w.outerr == nil {
+			n, err := io.WriteString(w.out, s)
+			w.written += n
+			w.outerr = err
+		}
+	}
+	w.str = w.str[:0]
+}
+
+// spill moves buffered string data out of w once it exceeds
+// streamChunkSize. It does nothing unless w is in a streaming mode.
+func (w *encbuf) spill() {
+	if w.mode == encBuffer || len(w.str) < streamChunkSize {
+		return
+	}
+	if w.mode == encSizing {
+		w.discarded += len(w.str)
+	} else {
+		w.writeOut(w.str)
+	}
+	w.str = w.str[:0]
+}
+
+func (w *encbuf) writeOut(b []byte) {
+	if w.outerr != nil || len(b) == 0 {
+		return
+	}
+	n, err := w.out.Write(b)
+	w.written += n
+	w.outerr = err
+}
+
+// encodeStreaming writes the encoding of val to out in two passes, keeping
+// at most streamChunkSize bytes of string data in memory.
+func (w *encbuf) encodeStreaming(out io.Writer, val interface{}) error {
+	rval := reflect.ValueOf(val)
+	writer, err := cachedWriter(rval.Type())
+	if err != nil {
+		return err
+	}
+	// The sizing pass computes all list headers.
+	w.mode = encSizing
+	if err := writer(rval, w); err != nil {
+		return err
+	}
+	size := w.size()
+
+	// The streaming pass writes the output, taking list headers from the
+	// sizing pass in the order their lists are started.
+	w.mode, w.out = encStreaming, out
+	w.nplan, w.lheads = len(w.lheads), w.lheads[:0]
+	w.str = w.str[:0]
+	if err := writer(rval, w); err != nil {
+		return err
+	}
+	w.writeOut(w.str)
+	w.str = w.str[:0]
+	if w.outerr != nil {
+		return w.outerr
+	}
+	if len(w.lheads) != w.nplan || w.written != size {
+		return errEncodingChanged
+	}
+	return nil
+}
+
 func (w *encbuf) encode(val interface{}) error {
 	rval := reflect.ValueOf(val)
 	writer, err := cachedWriter(rval.Type())
@@ -183,17 +420,40 @@ func (w *encbuf) encodeString(b []byte) {
 		w.str = append(w.str, b[0])
 	} else {
 		w.encodeStringHeader(len(b))
-		w.str = append(w.str, b...)
+		w.writeContent(b)
 	}
 }
 
 func (w *encbuf) list() *listhead {
-	lh := &listhead{offset: len(w.str), size: w.lhsize}
+	if w.mode == encStreaming {
+		return w.streamList()
+	}
+	lh := &listhead{offset: w.discarded + len(w.str), size: w.lhsize}
 	w.lheads = append(w.lheads, lh)
 	return lh
 }
 
+// streamList starts a list in streaming mode. The header is the one
+// computed for this list by the sizing pass.
+func (w *encbuf) streamList() *listhead {
+	n := len(w.lheads)
+	if n >= w.nplan {
+		if w.outerr == nil {
+			w.outerr = errEncodingChanged
+		}
+		return new(listhead)
+	}
+	w.lheads = w.lheads[:n+1]
+	lh := w.lheads[n]
+	w.str = append(w.str, lh.encode(w.sizebuf)...)
+	w.spill()
+	return lh
+}
+
 func (w *encbuf) listEnd(lh *listhead) {
+	if w.mode == encStreaming {
+		return
+	}
 	lh.size = w.size() - lh.offset - lh.size
 	if lh.size < 56 {
 		w.lhsize++ // length encoded into kind tag
@@ -203,7 +463,7 @@ func (w *encbuf) listEnd(lh *listhead) {
 }
 
 func (w *encbuf) size() int {
-	return len(w.str) + w.lhsize
+	return w.discarded + len(w.str) + w.lhsize
 }
 
 func (w *encbuf) toBytes() []byte {
@@ -322,6 +582,9 @@ var (
 
 // makeWriter creates a writer function for the given type.
 func makeWriter(typ reflect.Type, ts tags) (writer, error) {
+	if w, ok := registeredWriter(typ); ok {
+		return w, nil
+	}
 	kind := typ.Kind()
 	switch {
 	case typ == rawValueType:
@@ -338,6 +601,8 @@ func makeWriter(typ reflect.Type, ts tags) (writer, error) {
 		return makePtrWriter(typ, ts)
 	case reflect.PtrTo(typ).Implements(encoderInterface):
 		return makeEncoderWriter(typ), nil
+	case isBigEndianInteger(typ):
+		return makeBigEndianWriter(typ), nil
 	case isUint(kind):
 		return writeUint, nil
 	case isInt(kind):
@@ -356,6 +621,8 @@ func makeWriter(typ reflect.Type, ts tags) (writer, error) {
 		return makeSliceWriter(typ, ts)
 	case kind == reflect.Struct:
 		return makeStructWriter(typ)
+	case kind == reflect.Map:
+		return makeMapWriter(typ)
 	case kind == reflect.Interface:
 		return writeInterface, nil
 	default:
@@ -368,12 +635,16 @@ func isByte(typ reflect.Type) bool {
 }
 
 func writeRawValue(val reflect.Value, w *encbuf) error {
-	w.str = append(w.str, val.Bytes()...)
+	w.writeContent(val.Bytes())
 	return nil
 }
 
 func writeUint(val reflect.Value, w *encbuf) error {
-	i := val.Uint()
+	w.writeUint64(val.Uint())
+	return nil
+}
+
+func (w *encbuf) writeUint64(i uint64) {
 	if i == 0 {
 		w.str = append(w.str, 0x80)
 	} else if i < 128 {
@@ -385,11 +656,14 @@ func writeUint(val reflect.Value, w *encbuf) error {
 		w.sizebuf[0] = 0x80 + byte(s)
 		w.str = append(w.str, w.sizebuf[:s+1]...)
 	}
-	return nil
 }
 
 func writeInt(val reflect.Value, w *encbuf) error {
-	i := val.Int()
+	w.writeInt64(val.Int())
+	return nil
+}
+
+func (w *encbuf) writeInt64(i int64) {
 	if i < 0 {
 		ii := uint64(i * (-1))
 		if ii < 128 {
@@ -420,12 +694,19 @@ func writeInt(val reflect.Value, w *encbuf) error {
 			w.str = append(w.str, w.nsizebuf[:s+2]...)
 		}
 	}
-	return nil
 }
 
 func writeFloat(val reflect.Value, w *encbuf) error {
-	i := val.Float()
-	b := math.Float64bits(i)
+	w.writeFloat64(val.Float())
+	return nil
+}
+
+// writeFloat64 encodes f as a string holding its IEEE 754 binary64 bits
+// as a big endian integer without leading zero bytes. Unlike integers,
+// a non-zero value is always written with a string header, even when it
+// fits in a single byte below 0x80.
+func (w *encbuf) writeFloat64(f float64) {
+	b := math.Float64bits(f)
 	if b == 0 {
 		w.str = append(w.str, 0x80)
 	} else {
@@ -433,17 +714,19 @@ func writeFloat(val reflect.Value, w *encbuf) error {
 		w.sizebuf[0] = 0x80 + byte(s)
 		w.str = append(w.str, w.sizebuf[:s+1]...)
 	}
+}
 
+func writeBool(val reflect.Value, w *encbuf) error {
+	w.writeBool(val.Bool())
 	return nil
 }
 
-func writeBool(val reflect.Value, w *encbuf) error {
-	if val.Bool() {
+func (w *encbuf) writeBool(b bool) {
+	if b {
 		w.str = append(w.str, 0x01)
 	} else {
 		w.str = append(w.str, 0x80)
 	}
-	return nil
 }
 
 func writeBigIntPtr(val reflect.Value, w *encbuf) error {
@@ -466,20 +749,20 @@ func writeTimeTimePtr(val reflect.Value, w *encbuf) error {
 		w.str = append(w.str, 0x80)
 		return nil
 	}
-	b, err := ptr.MarshalBinary()
-	if err != nil {
-		return err
-	}
-	return writeBytes(reflect.ValueOf(b), w)
+	return w.writeTime(*ptr)
 }
 
 func writeTimeTimeNoPrt(val reflect.Value, w *encbuf) error {
-	i := val.Interface().(time.Time)
-	b, err := i.MarshalBinary()
+	return w.writeTime(val.Interface().(time.Time))
+}
+
+func (w *encbuf) writeTime(t time.Time) error {
+	b, err := t.MarshalBinary()
 	if err != nil {
 		return err
 	}
-	return writeBytes(reflect.ValueOf(b), w)
+	w.encodeString(b)
+	return nil
 }
 
 func writeBigInt(i *big.Int, w *encbuf) error {
@@ -513,15 +796,18 @@ func writeByteArray(val reflect.Value, w *encbuf) error {
 }
 
 func writeString(val reflect.Value, w *encbuf) error {
-	s := val.String()
+	w.writeString(val.String())
+	return nil
+}
+
+func (w *encbuf) writeString(s string) {
 	if len(s) == 1 && s[0] <= 0x7f {
 		// fits single byte, no string header
 		w.str = append(w.str, s[0])
 	} else {
 		w.encodeStringHeader(len(s))
-		w.str = append(w.str, s...)
+		w.writeStringContent(s)
 	}
-	return nil
 }
 
 func writeInterface(val reflect.Value, w *encbuf) error {
@@ -550,16 +836,82 @@ func makeSliceWriter(typ reflect.Type, ts tags) (writer, error) {
 			defer w.listEnd(w.list())
 		}
 		vlen := val.Len()
+		if w.workers > 1 && vlen >= parallelMinElems {
+			return w.writeElemsParallel(val, etypeinfo.writer)
+		}
 		for i := 0; i < vlen; i++ {
 			if err := etypeinfo.writer(val.Index(i), w); err != nil {
 				return err
 			}
+			w.spill()
 		}
 		return nil
 	}
 	return writer, nil
 }
 
+const (
+	// parallelMinElems is the minimum length of slices encoded in parallel.
+	parallelMinElems = 1024
+	// parallelMinChunk is the minimum number of elements per goroutine.
+	parallelMinChunk = 256
+)
+
+// writeElemsParallel encodes the elements of val in chunks on w.workers
+// goroutines. Each chunk is encoded into its own encbuf, and the results
+// are appended to w in order.
+func (w *encbuf) writeElemsParallel(val reflect.Value, write writer) error {
+	vlen := val.Len()
+	chunk := (vlen + w.workers - 1) / w.workers
+	if chunk < parallelMinChunk {
+		chunk = parallelMinChunk
+	}
+	n := (vlen + chunk - 1) / chunk
+	bufs := make([]*encbuf, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range bufs {
+		bufs[i] = encbufPool.Get().(*encbuf)
+		bufs[i].reset()
+		start, end := i*chunk, (i+1)*chunk
+		if end > vlen {
+			end = vlen
+		}
+		wg.Add(1)
+		go func(buf *encbuf, start, end int, err *error) {
+			defer wg.Done()
+			for j := start; j < end; j++ {
+				if *err = write(val.Index(j), buf); *err != nil {
+					return
+				}
+			}
+		}(bufs[i], start, end, &errs[i])
+	}
+	wg.Wait()
+
+	var err error
+	for i, buf := range bufs {
+		if err == nil {
+			if err = errs[i]; err == nil {
+				w.appendBuf(buf)
+			}
+		}
+		encbufPool.Put(buf)
+	}
+	return err
+}
+
+// appendBuf appends the content of b to w. All lists in b must be complete.
+func (w *encbuf) appendBuf(b *encbuf) {
+	base := len(w.str)
+	for _, lh := range b.lheads {
+		lh.offset += base
+		w.lheads = append(w.lheads, lh)
+	}
+	w.str = append(w.str, b.str...)
+	w.lhsize += b.lhsize
+}
+
 func makeStructWriter(typ reflect.Type) (writer, error) {
 	fields, err := structFields(typ)
 	if err != nil {
@@ -570,10 +922,22 @@ func makeStructWriter(typ reflect.Type) (writer, error) {
 			return nil, structFieldError{typ, f.index, f.info.writerErr}
 		}
 	}
+	firstOptional := firstOptionalField(fields)
+	version, versioned := structVersion(typ)
 	writer := func(val reflect.Value, w *encbuf) error {
+		// Trailing optional fields holding zero values are left out.
+		lastField := len(fields) - 1
+		for ; lastField >= firstOptional; lastField-- {
+			if !val.FieldByIndex(fields[lastField].index).IsZero() {
+				break
+			}
+		}
 		lh := w.list()
-		for _, f := range fields {
-			if err := f.info.writer(val.Field(f.index), w); err != nil {
+		if versioned {
+			w.writeUint64(version)
+		}
+		for _, f := range fields[:lastField+1] {
+			if err := f.info.writer(val.FieldByIndex(f.index), w); err != nil {
 				return err
 			}
 		}
@@ -583,6 +947,56 @@ func makeStructWriter(typ reflect.Type) (writer, error) {
 	return writer, nil
 }
 
+// mapEntry is a map element whose key has already been encoded.
+type mapEntry struct {
+	key []byte
+	val reflect.Value
+}
+
+// makeMapWriter creates a writer that encodes a map as a list of
+// [key, value] pairs. The pairs are sorted by the encoded bytes of their
+// keys so that the output doesn't depend on map iteration order.
+func makeMapWriter(typ reflect.Type) (writer, error) {
+	keyinfo := cachedTypeInfo1(typ.Key(), tags{})
+	if keyinfo.writerErr != nil {
+		return nil, keyinfo.writerErr
+	}
+	etypeinfo := cachedTypeInfo1(typ.Elem(), tags{})
+	if etypeinfo.writerErr != nil {
+		return nil, etypeinfo.writerErr
+	}
+	writer := func(val reflect.Value, w *encbuf) error {
+		lh := w.list()
+		if val.Len() > 0 {
+			kb := encbufPool.Get().(*encbuf)
+			defer encbufPool.Put(kb)
+
+			entries := make([]mapEntry, 0, val.Len())
+			for it := val.MapRange(); it.Next(); {
+				kb.reset()
+				if err := keyinfo.writer(it.Key(), kb); err != nil {
+					return err
+				}
+				entries = append(entries, mapEntry{kb.toBytes(), it.Value()})
+			}
+			sort.Slice(entries, func(i, j int) bool {
+				return bytes.Compare(entries[i].key, entries[j].key) < 0
+			})
+			for _, e := range entries {
+				plh := w.list()
+				w.str = append(w.str, e.key...)
+				if err := etypeinfo.writer(e.val, w); err != nil {
+					return err
+				}
+				w.listEnd(plh)
+			}
+		}
+		w.listEnd(lh)
+		return nil
+	}
+	return writer, nil
+}
+
 func makePtrWriter(typ reflect.Type, ts tags) (writer, error) {
 	etypeinfo := cachedTypeInfo1(typ.Elem(), tags{})
 	if etypeinfo.writerErr != nil {
@@ -18,11 +18,14 @@ package rlp
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"math/big"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -167,12 +170,12 @@ var encTests = []encTest{
 	},
 
 	// slices
-	{val: []uint{}, output: "C0"},
-	{val: []uint{1, 2, 3}, output: "C3010203"},
+	{val: []uint{}, output: `[]`},
+	{val: []uint{1, 2, 3}, output: `[1, 2, 3]`},
 	{
 		// [ [], [[]], [ [], [[]] ] ]
 		val:    []interface{}{[]interface{}{}, [][]interface{}{{}}, []interface{}{[]interface{}{}, [][]interface{}{{}}}},
-		output: "C7C0C1C0C3C0C1C0",
+		output: `[[], [[]], [[], [[]]]]`,
 	},
 	{
 		val:    []string{"aaa", "bbb", "ccc", "ddd", "eee", "fff", "ggg", "hhh", "iii", "jjj", "kkk", "lll", "mmm", "nnn", "ooo"},
@@ -180,7 +183,7 @@ var encTests = []encTest{
 	},
 	{
 		val:    []interface{}{uint(1), uint(0xFFFFFF), []interface{}{[]uint{4, 5, 5}}, "abc"},
-		output: "CE0183FFFFFFC4C304050583616263",
+		output: `[1, 0xffffff, [[4, 5, 5]], "abc"]`,
 	},
 	{
 		val: [][]string{
@@ -220,23 +223,48 @@ var encTests = []encTest{
 		output: "F90200CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376CF84617364668471776572847A786376",
 	},
 
+	// maps
+	{val: map[string]uint{}, output: `[]`},
+	{val: map[string]uint(nil), output: `[]`},
+	{val: map[string]uint{"b": 2, "a": 1}, output: `[["a", 1], ["b", 2]]`},
+	{val: map[uint]uint{0x80: 1, 2: 2}, output: `[[2, 2], [128, 1]]`},
+	{val: map[string][]uint{"x": {1, 2}}, output: `[["x", [1, 2]]]`},
+	{val: map[[2]uint]string{{2, 1}: "b", {1, 2}: "a"}, output: `[[[1, 2], "a"], [[2, 1], "b"]]`},
+	{val: map[string]chan bool{"a": nil}, error: "rlp: type chan bool is not RLP-serializable"},
+
 	// RawValue
 	{val: RawValue(unhex("01")), output: "01"},
 	{val: RawValue(unhex("82FFFF")), output: "82FFFF"},
-	{val: []RawValue{unhex("01"), unhex("02")}, output: "C20102"},
+	{val: []RawValue{unhex("01"), unhex("02")}, output: `[1, 2]`},
 
 	// structs
-	{val: simplestruct{}, output: "C28080"},
-	{val: simplestruct{A: 3, B: "foo"}, output: "C50383666F6F"},
-	{val: &recstruct{5, nil}, output: "C205C0"},
-	{val: &recstruct{5, &recstruct{4, &recstruct{3, nil}}}, output: "C605C404C203C0"},
-	{val: &tailRaw{A: 1, Tail: []RawValue{unhex("02"), unhex("03")}}, output: "C3010203"},
-	{val: &tailRaw{A: 1, Tail: []RawValue{unhex("02")}}, output: "C20102"},
-	{val: &tailRaw{A: 1, Tail: []RawValue{}}, output: "C101"},
-	{val: &tailRaw{A: 1, Tail: nil}, output: "C101"},
-	{val: &hasIgnoredField{A: 1, B: 2, C: 3}, output: "C20103"},
-	{val: &intField{X: 3}, output: "C3820003"},
-	{val: &intField{X: -3}, output: "C3820103"},
+	{val: simplestruct{}, output: `["", ""]`},
+	{val: simplestruct{A: 3, B: "foo"}, output: `[3, "foo"]`},
+	{val: &recstruct{5, nil}, output: `[5, []]`},
+	{val: &recstruct{5, &recstruct{4, &recstruct{3, nil}}}, output: `[5, [4, [3, []]]]`},
+	{val: &tailRaw{A: 1, Tail: []RawValue{unhex("02"), unhex("03")}}, output: `[1, 2, 3]`},
+	{val: &tailRaw{A: 1, Tail: []RawValue{unhex("02")}}, output: `[1, 2]`},
+	{val: &tailRaw{A: 1, Tail: []RawValue{}}, output: `[1]`},
+	{val: &tailRaw{A: 1, Tail: nil}, output: `[1]`},
+	{val: &hasIgnoredField{A: 1, B: 2, C: 3}, output: `[1, 3]`},
+	{val: &optionalFields{A: 1}, output: `[1]`},
+	{val: &optionalFields{A: 1, B: 2}, output: `[1, 2]`},
+	{val: &optionalFields{A: 1, B: 2, C: 3}, output: `[1, 2, 3]`},
+	{val: &optionalFields{A: 1, B: 0, C: 3}, output: `[1, "", 3]`},
+	{val: &optionalAndTailField{A: 1}, output: `[1]`},
+	{val: &optionalAndTailField{A: 1, Tail: []uint{}}, output: `[1, ""]`},
+	{val: &optionalAndTailField{A: 1, Tail: []uint{5, 6}}, output: `[1, "", 5, 6]`},
+	{val: &optionalBigIntField{A: 1}, output: `[1]`},
+	{val: &optionalPtrField{A: 1}, output: `[1]`},
+	{val: &optionalPtrField{A: 1, B: &[3]byte{1, 2, 3}}, output: `[1, "0x010203"]`},
+	{val: &nonOptionalPtrField{A: 1}, output: `[1, ""]`},
+	{val: &inlineFields{Base{1, Audit{2, 3}}, "z"}, output: `[1, 2, 3, "z"]`},
+	{val: &embeddedFields{Base{1, Audit{2, 3}}, "z"}, output: `[[1, 2, 3], "z"]`},
+	{val: &inlineOptional{A: 1}, output: `[1]`},
+	{val: &inlineOptional{1, OptionalAudit{2}}, output: `[1, 2]`},
+	// Signed integers are a sign byte, 0 or 1, followed by the magnitude.
+	{val: &intField{X: 3}, output: `["0x0003"]`},
+	{val: &intField{X: -3}, output: `["0x0103"]`},
 
 	// nil
 	{val: (*uint)(nil), output: "80"},
@@ -326,7 +354,7 @@ func runEncTests(t *testing.T, f func(val interface{}) ([]byte, error)) {
 				i, err, test.error, test.val, test.val)
 			continue
 		}
-		if err == nil && !bytes.Equal(output, unhex(test.output)) {
+		if err == nil && !bytes.Equal(output, fixture(test.output)) {
 			t.Errorf("test %d: output mismatch:\ngot   %X\nwant  %s\nvalue %#v\ntype  %T",
 				i, output, test.output, test.val, test.val)
 		}
@@ -382,6 +410,318 @@ func TestEncodeToReaderPiecewise(t *testing.T) {
 	})
 }
 
+func TestHashableEncode(t *testing.T) {
+	tests := []struct {
+		val    interface{}
+		output string
+		error  string
+	}{
+		// List boundaries are kept.
+		{val: []string{"ab", "c"}, output: "C482616263"},
+		{val: []string{"a", "bc"}, output: "C461826263"},
+		{val: []interface{}{[]int{1, 2}, []float64{0.5}}, output: "D1C6820001820002C9883FE0000000000000"},
+		{val: map[string]interface{}{"b": []uint{2}, "a": uint(1)}, output: "C7C26101C362C102"},
+		{val: &simplestruct{A: 3, B: "foo"}, output: "C50383666F6F"},
+		{val: RawValue(unhex("C20102")), output: "C20102"},
+
+		// Invalid output of RawValues and Encoders is rejected.
+		{val: RawValue(unhex("C3")), error: "rlp: invalid encoding of rlp.RawValue for hashing: rlp: value size exceeds available input length"},
+		{val: RawValue(unhex("0102")), error: "rlp: invalid encoding of rlp.RawValue for hashing: rlp: input contains more than one value"},
+		{val: []RawValue{unhex("8105")}, error: "rlp: invalid encoding of []rlp.RawValue for hashing: rlp: non-canonical size information"},
+		{val: &testEncoder{}, error: "rlp: invalid encoding of *rlp.testEncoder for hashing: rlp: input contains more than one value"},
+		{val: undecodableEncoder(func() {}), error: "rlp: invalid encoding of rlp.undecodableEncoder for hashing: rlp: value size exceeds available input length"},
+	}
+	for i, test := range tests {
+		var b bytes.Buffer
+		err := HashableEncode(&b, test.val)
+		if test.error != "" {
+			if fmt.Sprint(err) != test.error {
+				t.Errorf("test %d: error mismatch\ngot  %v\nwant %s", i, err, test.error)
+			}
+			if b.Len() > 0 {
+				t.Errorf("test %d: output written despite error: %X", i, b.Bytes())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(b.Bytes(), fixture(test.output)) {
+			t.Errorf("test %d: output mismatch\ngot  %X\nwant %s", i, b.Bytes(), test.output)
+		}
+	}
+}
+
+// bufferHash is a hash.Hash that keeps its input.
+type bufferHash struct{ bytes.Buffer }
+
+func (h *bufferHash) Sum(b []byte) []byte { return append(b, h.Bytes()...) }
+func (h *bufferHash) Size() int           { return h.Len() }
+func (h *bufferHash) BlockSize() int      { return 1 }
+
+func TestEncodeToHash(t *testing.T) {
+	runEncTests(t, func(val interface{}) ([]byte, error) {
+		h := new(bufferHash)
+		err := EncodeToHash(h, val)
+		return h.Bytes(), err
+	})
+}
+
+// largeValue returns a value whose encoding is a few megabytes, made of
+// large strings and many small lists.
+func largeValue() interface{} {
+	small := make([][]uint, 20000)
+	for i := range small {
+		small[i] = []uint{uint(i), uint(i * i)}
+	}
+	return []interface{}{
+		bytes.Repeat([]byte{0xAB}, 1<<20),
+		[]string{strings.Repeat("x", 100000), "y", strings.Repeat("z", 4096)},
+		[]RawValue{unhex("C20102"), RawValue(bytes.Repeat([]byte{1}, 1<<20))},
+		small,
+		map[string][]byte{"k": bytes.Repeat([]byte{0xCD}, 1<<20)},
+	}
+}
+
+func TestEncodeToHashLarge(t *testing.T) {
+	val := largeValue()
+	enc, err := EncodeToBytes(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := new(bufferHash)
+	if err := EncodeToHash(h, val); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(h.Bytes(), enc) {
+		t.Fatalf("output mismatch (len %d, want %d)", h.Len(), len(enc))
+	}
+
+	// The encoding must not be buffered while hashing: the largest write
+	// to the hash is one of the large strings, not the whole encoding.
+	sha := &countingHash{Hash: sha256.New()}
+	if err := EncodeToHash(sha, val); err != nil {
+		t.Fatal(err)
+	}
+	if sha.w.n != len(enc) {
+		t.Errorf("hashed %d bytes, want %d", sha.w.n, len(enc))
+	}
+	if sha.w.largest != 1<<20 {
+		t.Errorf("largest write has %d bytes, want %d", sha.w.largest, 1<<20)
+	}
+	if want := sha256.Sum256(enc); !bytes.Equal(sha.Sum(nil), want[:]) {
+		t.Errorf("hash mismatch")
+	}
+}
+
+// countingHash is a hash.Hash that records the sizes of writes to it.
+type countingHash struct {
+	hash.Hash
+	w countingWriter
+}
+
+func (h *countingHash) Write(b []byte) (int, error) {
+	h.w.Write(b)
+	return h.Hash.Write(b)
+}
+
+func TestEncodeStreaming(t *testing.T) {
+	runEncTests(t, func(val interface{}) ([]byte, error) {
+		var buf bytes.Buffer
+		err := EncodeStreaming(&buf, val)
+		return buf.Bytes(), err
+	})
+}
+
+// countingWriter counts the bytes written to it and records the size of
+// the largest write.
+type countingWriter struct {
+	n, largest int
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.n += len(b)
+	if len(b) > w.largest {
+		w.largest = len(b)
+	}
+	return len(b), nil
+}
+
+func TestEncodeStreamingLarge(t *testing.T) {
+	val := largeValue()
+	enc, err := EncodeToBytes(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := EncodeStreaming(&buf, val); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), enc) {
+		t.Fatalf("output mismatch (len %d, want %d)", buf.Len(), len(enc))
+	}
+
+	// The large strings are passed to the writer as they are, and the
+	// buffer never holds more than a few chunks of string data.
+	w := new(countingWriter)
+	eb := encbufPool.New().(*encbuf)
+	if err := eb.encodeStreaming(w, val); err != nil {
+		t.Fatal(err)
+	}
+	if w.n != len(enc) {
+		t.Errorf("wrote %d bytes, want %d", w.n, len(enc))
+	}
+	if w.largest != 1<<20 {
+		t.Errorf("largest write has %d bytes, want %d", w.largest, 1<<20)
+	}
+	if max := 2 * streamChunkSize; cap(eb.str) > max {
+		t.Errorf("buffer grew to %d bytes, want at most %d", cap(eb.str), max)
+	}
+}
+
+// failingWriter fails once more than limit bytes are written.
+type failingWriter struct {
+	limit int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if len(b) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errWriteFailed
+	}
+	w.limit -= len(b)
+	return len(b), nil
+}
+
+func TestEncodeStreamingWriteError(t *testing.T) {
+	for _, limit := range []int{0, 100, 5000, 1 << 21} {
+		err := EncodeStreaming(&failingWriter{limit}, largeValue())
+		if err != errWriteFailed {
+			t.Errorf("limit %d: wrong error: %v", limit, err)
+		}
+	}
+}
+
+// changingEncoder encodes as a list with one more element on each call.
+type changingEncoder struct{ n int }
+
+func (e *changingEncoder) EncodeRLP(w io.Writer) error {
+	e.n++
+	return Encode(w, make([]uint, e.n))
+}
+
+func TestEncodeToHashChangingEncoder(t *testing.T) {
+	err := EncodeToHash(sha256.New(), []interface{}{&changingEncoder{}})
+	if err != errEncodingChanged {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+func TestEncodeToBytesParallel(t *testing.T) {
+	runEncTests(t, func(val interface{}) ([]byte, error) {
+		return EncodeToBytesParallel(val, 4)
+	})
+}
+
+type parallelTestStruct struct {
+	A    uint
+	B    string
+	C    []uint16
+	D    *big.Int
+	E    map[string]uint
+	Rest []uint `rlp:"tail"`
+}
+
+func parallelTestValues(n int) []interface{} {
+	structs := make([]parallelTestStruct, n)
+	nested := make([][]uint, n)
+	var array [3000]string
+	for i := range structs {
+		structs[i] = parallelTestStruct{
+			A: uint(i),
+			B: strings.Repeat("b", i%70),
+			C: []uint16{uint16(i), 2},
+			D: big.NewInt(int64(i) << 40),
+			E: map[string]uint{"x": uint(i)},
+		}
+		nested[i] = make([]uint, i%5)
+	}
+	for i := range array {
+		array[i] = fmt.Sprint(i)
+	}
+	tail := parallelTestStruct{Rest: make([]uint, n)}
+	for i := range tail.Rest {
+		tail.Rest[i] = uint(i)
+	}
+	return []interface{}{
+		structs,
+		nested,
+		&array,
+		tail,
+		[]interface{}{structs[:1500], nested},
+		make([]*testEncoder, 2000),
+	}
+}
+
+func TestEncodeToBytesParallelLarge(t *testing.T) {
+	for i, val := range parallelTestValues(5000) {
+		want, err := EncodeToBytes(val)
+		if err != nil {
+			t.Fatalf("value %d: %v", i, err)
+		}
+		for _, workers := range []int{0, 1, 2, 3, 8, 64} {
+			got, err := EncodeToBytesParallel(val, workers)
+			if err != nil {
+				t.Fatalf("value %d, %d workers: %v", i, workers, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("value %d, %d workers: output differs from serial encoding", i, workers)
+			}
+		}
+	}
+}
+
+func TestEncodeToBytesParallelError(t *testing.T) {
+	val := make([]*testEncoder, 10000)
+	val[3000] = &testEncoder{err: errors.New("first")}
+	val[9000] = &testEncoder{err: errors.New("second")}
+	for _, workers := range []int{2, 8} {
+		if _, err := EncodeToBytesParallel(val, workers); fmt.Sprint(err) != "first" {
+			t.Errorf("%d workers: wrong error %v", workers, err)
+		}
+	}
+}
+
+func BenchmarkEncodeLargeSlice(b *testing.B) {
+	val := parallelTestValues(100000)[0]
+	enc, _ := EncodeToBytes(val)
+	b.Run("serial", func(b *testing.B) {
+		b.SetBytes(int64(len(enc)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := EncodeToBytes(val); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	for _, workers := range []int{2, 4, 8} {
+		workers := workers
+		b.Run(fmt.Sprintf("parallel-%d", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(enc)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := EncodeToBytesParallel(val, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 // This is a regression test verifying that encReader
 // returns its encbuf to the pool only once.
 func TestEncodeToReaderReturnToPool(t *testing.T) {
@@ -0,0 +1,67 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build go1.18
+// +build go1.18
+
+package rlp
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+// fuzzLimits are the limits used by FuzzDecodeBytes. They are small enough
+// that inputs exceeding them are easy to find.
+var fuzzLimits = Limits{MaxDepth: 32, MaxAlloc: 1 << 16, MaxListElems: 256}
+
+// FuzzDecodeBytes checks that decoding arbitrary input with limits doesn't
+// panic and that values decoded into interface{} re-encode to the input.
+// The seed corpus in testdata/fuzz/FuzzDecodeBytes covers inputs that
+// exceed each of the limits.
+func FuzzDecodeBytes(f *testing.F) {
+	f.Add(nestedLists(fuzzLimits.MaxDepth + 1))
+	f.Add(unhex("BBFFFFFFFF"))
+	f.Fuzz(func(t *testing.T, input []byte) {
+		var v interface{}
+		if err := DecodeBytesWithLimits(input, &v, fuzzLimits); err == nil {
+			enc, err := EncodeToBytes(v)
+			if err != nil {
+				t.Fatalf("can't re-encode decoded value: %v", err)
+			}
+			if !bytes.Equal(enc, input) {
+				t.Fatalf("re-encoding mismatch:\n input %x\noutput %x", input, enc)
+			}
+		}
+		targets := []interface{}{
+			new(RawValue),
+			new([]byte),
+			new([][]uint64),
+			new(map[string][]byte),
+			new(*big.Int),
+			new(struct {
+				A uint
+				B []string
+				C *struct{ D []int64 } `rlp:"nil"`
+				E []float64            `rlp:"tail"`
+			}),
+		}
+		for _, ptr := range targets {
+			DecodeBytesWithLimits(input, ptr, fuzzLimits)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import "io"
+
+// ListIterator is an iterator over the elements of an encoded RLP list.
+// It works on the input slice directly and doesn't allocate.
+type ListIterator struct {
+	data []byte
+	next []byte
+	err  error
+}
+
+// NewListIterator creates an iterator for the list contained in data.
+// It returns ErrExpectedList if data is not an RLP list and
+// ErrMoreThanOneValue if data contains anything after the list.
+func NewListIterator(data RawValue) (*ListIterator, error) {
+	content, rest, err := SplitList(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, ErrMoreThanOneValue
+	}
+	return &ListIterator{data: content}, nil
+}
+
+// Next forwards the iterator to the next element. It returns false when
+// the end of the list is reached or the element is invalid, in which
+// case Err returns the error.
+func (it *ListIterator) Next() bool {
+	it.next = nil
+	if len(it.data) == 0 || it.err != nil {
+		return false
+	}
+	_, tagsize, size, err := readKind(it.data)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.next = it.data[:tagsize+size]
+	it.data = it.data[tagsize+size:]
+	return true
+}
+
+// Value returns the current element, including its RLP header. The value
+// is a subslice of the input.
+func (it *ListIterator) Value() RawValue {
+	return it.next
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *ListIterator) Err() error {
+	return it.err
+}
+
+// StreamIterator is an iterator over the values of a Stream.
+// It is created by Stream.Iter.
+type StreamIterator struct {
+	s        *Stream
+	toplevel bool
+	value    []byte
+	done     bool
+	err      error
+}
+
+// Iter returns an iterator over the values remaining in the list that
+// the stream is positioned in. To iterate over the elements of a list
+// value, call List before Iter and ListEnd after the iteration is done.
+// If the stream is not inside a list, the iterator yields the remaining
+// top-level values until the end of the input, which is useful for
+// reading files made of concatenated RLP records.
+//
+// The iterator reads one value at a time. The slice returned by Value is
+// reused by the next call to Next, except in zero-copy mode (see
+// NewByteStream), where it is a subslice of the input.
+func (s *Stream) Iter() *StreamIterator {
+	return &StreamIterator{s: s, toplevel: len(s.stack) == 0}
+}
+
+// Next reads the next value. It returns false at the end of the list or
+// input, or when the value can't be read, in which case Err returns the
+// error.
+func (it *StreamIterator) Next() bool {
+	if it.done || it.err != nil {
+		return false
+	}
+	v, err := it.s.raw(it.value)
+	switch {
+	case err == nil:
+		it.value = v
+		return true
+	case err == ErrEOL || (err == io.EOF && it.toplevel):
+		it.done = true
+	default:
+		it.err = err
+	}
+	it.value = it.value[:0]
+	return false
+}
+
+// Value returns the current value, including its RLP header.
+func (it *StreamIterator) Value() RawValue {
+	return it.value
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *StreamIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,185 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestListIterator(t *testing.T) {
+	input := unhex("C9" + "01" + "80" + "C20102" + "83616263")
+	want := []string{"01", "80", "C20102", "83616263"}
+
+	it, err := NewListIterator(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for it.Next() {
+		got = append(got, hexString(it.Value()))
+	}
+	if it.Err() != nil {
+		t.Fatal("unexpected error:", it.Err())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong values: got %v, want %v", got, want)
+	}
+	if it.Next() || it.Value() != nil {
+		t.Fatal("Next returned true after end of list")
+	}
+}
+
+func TestListIteratorErrors(t *testing.T) {
+	if _, err := NewListIterator(unhex("8201")); err != ErrValueTooLarge {
+		t.Errorf("wrong error for truncated input: %v", err)
+	}
+	if _, err := NewListIterator(unhex("820102")); err != ErrExpectedList {
+		t.Errorf("wrong error for string input: %v", err)
+	}
+	if _, err := NewListIterator(unhex("C0C0")); err != ErrMoreThanOneValue {
+		t.Errorf("wrong error for trailing data: %v", err)
+	}
+
+	// The first element is fine, the second one is non-canonical.
+	it, err := NewListIterator(unhex("C3018105"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !it.Next() || !bytes.Equal(it.Value(), unhex("01")) {
+		t.Fatal("first element not returned")
+	}
+	if it.Next() {
+		t.Fatal("Next returned true for invalid element")
+	}
+	if it.Err() != ErrCanonSize {
+		t.Fatalf("wrong error: %v", it.Err())
+	}
+}
+
+func TestListIteratorAllocs(t *testing.T) {
+	input, _ := EncodeToBytes(make([]uint, 1000))
+	allocs := testing.AllocsPerRun(10, func() {
+		it := ListIterator{data: input[3:]}
+		for it.Next() {
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("iteration allocated %v times", allocs)
+	}
+}
+
+func TestStreamIterator(t *testing.T) {
+	input := unhex("C9" + "01" + "80" + "C20102" + "83616263" + "C0")
+	for _, zeroCopy := range []bool{false, true} {
+		var s *Stream
+		if zeroCopy {
+			s = NewByteStream(input)
+		} else {
+			s = NewStream(bytes.NewReader(input), 0)
+		}
+		if _, err := s.List(); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for it := s.Iter(); it.Next(); {
+			got = append(got, hexString(it.Value()))
+		}
+		want := []string{"01", "80", "C20102", "83616263"}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("zeroCopy=%t: wrong values: got %v, want %v", zeroCopy, got, want)
+		}
+		if err := s.ListEnd(); err != nil {
+			t.Fatal(err)
+		}
+		// The value after the list is still available.
+		if v, err := s.Raw(); err != nil || !bytes.Equal(v, unhex("C0")) {
+			t.Fatalf("zeroCopy=%t: wrong value after list: %x, %v", zeroCopy, v, err)
+		}
+	}
+}
+
+func TestStreamIteratorToplevel(t *testing.T) {
+	// Concatenated records, as in an append-only file.
+	input := unhex("C20102" + "05" + "83616263" + "C0")
+	s := NewStream(bytes.NewReader(input), 0)
+	it := s.Iter()
+	var got []string
+	for it.Next() {
+		got = append(got, hexString(it.Value()))
+	}
+	if it.Err() != nil {
+		t.Fatal("unexpected error:", it.Err())
+	}
+	want := []string{"C20102", "05", "83616263", "C0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong values: got %v, want %v", got, want)
+	}
+}
+
+func TestStreamIteratorErrors(t *testing.T) {
+	// Truncated record at the end of the input. The reader is wrapped
+	// so that the stream doesn't know the input size in advance.
+	s := NewStream(io.MultiReader(bytes.NewReader(unhex("C2010283616263")[:6])), 0)
+	it := s.Iter()
+	if !it.Next() {
+		t.Fatal("first record not returned")
+	}
+	if it.Next() {
+		t.Fatal("Next returned true for truncated record")
+	}
+	if it.Err() != io.ErrUnexpectedEOF {
+		t.Fatalf("wrong error: %v", it.Err())
+	}
+
+	// Element larger than the containing list.
+	s = NewStream(bytes.NewReader(unhex("C20183616263")), 0)
+	s.List()
+	it = s.Iter()
+	if !it.Next() {
+		t.Fatal("first element not returned")
+	}
+	if it.Next() || it.Err() != ErrElemTooLarge {
+		t.Fatalf("wrong error: %v", it.Err())
+	}
+}
+
+func TestStreamIteratorAllocs(t *testing.T) {
+	input, _ := EncodeToBytes(make([][]byte, 1000))
+	r := bytes.NewReader(input)
+	s := NewStream(r, 0)
+	allocs := testing.AllocsPerRun(10, func() {
+		r.Reset(input)
+		s.Reset(r, 0)
+		s.List()
+		it := StreamIterator{s: s, value: make([]byte, 0, 8)}
+		for it.Next() {
+		}
+	})
+	// Only the value buffer is allocated.
+	if allocs > 1 {
+		t.Fatalf("iteration allocated %v times", allocs)
+	}
+}
+
+func hexString(b []byte) string {
+	return strings.ToUpper(hex.EncodeToString(b))
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// nestedLists returns the encoding of depth nested empty lists.
+func nestedLists(depth int) []byte {
+	b := []byte{0xC0}
+	for i := 1; i < depth; i++ {
+		var err error
+		if b, err = EncodeToBytes([]RawValue{b}); err != nil {
+			panic(err)
+		}
+	}
+	return b
+}
+
+var limitTests = []struct {
+	input  []byte
+	ptr    interface{}
+	limits Limits
+	err    error
+}{
+	// MaxDepth
+	{input: nestedLists(3), ptr: new(interface{}), limits: Limits{MaxDepth: 3}},
+	{input: nestedLists(4), ptr: new(interface{}), limits: Limits{MaxDepth: 3}, err: ErrTooDeep},
+	{input: nestedLists(10000), ptr: new(interface{}), limits: Limits{MaxDepth: 64}, err: ErrTooDeep},
+	{input: unhex("C3C2C180"), ptr: new([][][]uint), limits: Limits{MaxDepth: 1}, err: ErrTooDeep},
+	{input: unhex("83616263"), ptr: new(interface{}), limits: Limits{MaxDepth: 1}},
+
+	// MaxListElems
+	{input: unhex("C3010203"), ptr: new([]uint), limits: Limits{MaxListElems: 3}},
+	{input: unhex("C3010203"), ptr: new([]uint), limits: Limits{MaxListElems: 2}, err: ErrTooManyElements},
+	{input: unhex("C4C2010203"), ptr: new(interface{}), limits: Limits{MaxListElems: 2}},
+	{input: unhex("C4C3010203"), ptr: new(interface{}), limits: Limits{MaxListElems: 2}, err: ErrTooManyElements},
+	{input: unhex("C3010203"), ptr: new(RawValue), limits: Limits{MaxListElems: 1}},
+
+	// MaxAlloc
+	{input: unhex("8A00010203040506070809"), ptr: new([]byte), limits: Limits{MaxAlloc: 10}},
+	{input: unhex("8A00010203040506070809"), ptr: new([]byte), limits: Limits{MaxAlloc: 9}, err: ErrAllocLimit},
+	{input: unhex("8A00010203040506070809"), ptr: new(RawValue), limits: Limits{MaxAlloc: 10}, err: ErrAllocLimit},
+	{input: unhex("C3010203"), ptr: new([]uint64), limits: Limits{MaxAlloc: 32}},
+	{input: unhex("C3010203"), ptr: new([]uint64), limits: Limits{MaxAlloc: 31}, err: ErrAllocLimit},
+	{input: unhex("C6C26101C26202"), ptr: new(map[string]uint), limits: Limits{MaxAlloc: 48}},
+	{input: unhex("C6C26101C26202"), ptr: new(map[string]uint), limits: Limits{MaxAlloc: 47}, err: ErrAllocLimit},
+	{input: unhex("05"), ptr: new(*uint64), limits: Limits{MaxAlloc: 8}},
+	{input: unhex("05"), ptr: new(*uint64), limits: Limits{MaxAlloc: 7}, err: ErrAllocLimit},
+}
+
+func TestDecodeBytesWithLimits(t *testing.T) {
+	for i, test := range limitTests {
+		// Decode into a fresh value because allocations for a value that
+		// already exists aren't counted.
+		ptr := reflect.New(reflect.TypeOf(test.ptr).Elem()).Interface()
+		err := DecodeBytesWithLimits(test.input, ptr, test.limits)
+		if err != test.err {
+			t.Errorf("test %d: error mismatch: got %v, want %v (decoding into %T)", i, err, test.err, test.ptr)
+		}
+		// Without limits, all inputs are valid.
+		if err := DecodeBytes(test.input, ptr); err != nil {
+			t.Errorf("test %d: unexpected error without limits: %v", i, err)
+		}
+	}
+}
+
+func TestStreamLimitsHugeString(t *testing.T) {
+	// The input declares a 4GB string, but the reader has no known
+	// length. The allocation limit must reject it before allocating.
+	input := unhex("BBFFFFFFFF")
+	s := NewStream(io.MultiReader(bytes.NewReader(input)), 0)
+	s.SetLimits(Limits{MaxAlloc: 1 << 20})
+	if _, err := s.Bytes(); err != ErrAllocLimit {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+func TestStreamLimitsAccumulate(t *testing.T) {
+	// The allocation limit covers all values read from the stream.
+	input := unhex("83616263 83646566 83676869")
+	s := NewStream(bytes.NewReader(input), 0)
+	s.SetLimits(Limits{MaxAlloc: 6})
+	for i := 0; i < 2; i++ {
+		if _, err := s.Bytes(); err != nil {
+			t.Fatalf("value %d: unexpected error: %v", i, err)
+		}
+	}
+	if _, err := s.Bytes(); err != ErrAllocLimit {
+		t.Fatalf("wrong error for third value: %v", err)
+	}
+}
+
+func TestStreamResetClearsLimits(t *testing.T) {
+	input := nestedLists(3)
+	s := NewStream(bytes.NewReader(input), 0)
+	s.SetLimits(Limits{MaxDepth: 1})
+	var v interface{}
+	if err := s.Decode(&v); err != ErrTooDeep {
+		t.Fatalf("wrong error: %v", err)
+	}
+	s.Reset(bytes.NewReader(input), 0)
+	if err := s.Decode(&v); err != nil {
+		t.Fatalf("unexpected error after Reset: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// TypeWriter writes the encoding of val to w. The type of val is the type
+// given to RegisterType.
+type TypeWriter func(w EncoderBuffer, val reflect.Value) error
+
+// TypeDecoder decodes the next value in s into val. val is settable and
+// has the type given to RegisterType.
+type TypeDecoder func(s *Stream, val reflect.Value) error
+
+type registeredType struct {
+	writer  TypeWriter
+	decoder TypeDecoder
+}
+
+// registry holds the types added by RegisterType.
+// It is protected by typeCacheMutex.
+var registry = make(map[reflect.Type]registeredType)
+
+// RegisterType sets the functions used to encode and decode values of typ.
+// This makes it possible to support types defined in other packages, such
+// as net.IP or sql.NullString, without wrapping them in a type implementing
+// Encoder and Decoder.
+//
+// Registered types take precedence over all other encoding and decoding
+// rules, including the Encoder and Decoder interfaces. They can be used
+// anywhere, e.g. as struct fields, slice elements or map values, and
+// struct tags apply as for other types. In particular, the "nil" tags
+// control how nil pointers to registered types are encoded and decoded.
+// Code generated by rlpgen doesn't use registered types.
+//
+// RegisterType should be called during initialization, before typ is used.
+// It panics if typ is an interface type, if typ has already been registered,
+// or if either function is nil.
+func RegisterType(typ reflect.Type, writer TypeWriter, decoder TypeDecoder) {
+	if typ == nil || writer == nil || decoder == nil {
+		panic("rlp: RegisterType called with nil argument")
+	}
+	if typ.Kind() == reflect.Interface {
+		panic(fmt.Sprintf("rlp: can't register interface type %v", typ))
+	}
+	typeCacheMutex.Lock()
+	defer typeCacheMutex.Unlock()
+	if _, dup := registry[typ]; dup {
+		panic(fmt.Sprintf("rlp: RegisterType called twice for type %v", typ))
+	}
+	registry[typ] = registeredType{writer, decoder}
+	// Cached codecs of types containing typ are outdated now.
+	typeCache = make(map[typekey]*typeinfo)
+}
+
+// registeredWriter returns the writer of a registered type.
+// It must be called with typeCacheMutex held.
+func registeredWriter(typ reflect.Type) (writer, bool) {
+	rt, ok := registry[typ]
+	if !ok {
+		return nil, false
+	}
+	return func(val reflect.Value, w *encbuf) error {
+		return rt.writer(EncoderBuffer{buf: w}, val)
+	}, true
+}
+
+// registeredDecoder returns the decoder of a registered type.
+// It must be called with typeCacheMutex held.
+func registeredDecoder(typ reflect.Type) (decoder, bool) {
+	rt, ok := registry[typ]
+	if !ok {
+		return nil, false
+	}
+	return func(s *Stream, val reflect.Value) error {
+		return wrapStreamError(rt.decoder(s, val), val.Type())
+	}, true
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// nullString is like sql.NullString. It is registered to encode as an RLP
+// string if valid and as an empty list otherwise.
+type nullString struct {
+	String string
+	Valid  bool
+}
+
+func init() {
+	RegisterType(reflect.TypeOf(nullString{}), writeNullString, decodeNullString)
+}
+
+func writeNullString(w EncoderBuffer, val reflect.Value) error {
+	ns := val.Interface().(nullString)
+	if !ns.Valid {
+		w.Write(EmptyList)
+		return nil
+	}
+	w.WriteString(ns.String)
+	return nil
+}
+
+func decodeNullString(s *Stream, val reflect.Value) error {
+	kind, size, err := s.Kind()
+	if err != nil {
+		return err
+	}
+	if kind == List {
+		if size != 0 {
+			return errors.New("rlp: invalid null string")
+		}
+		s.List()
+		val.Set(reflect.ValueOf(nullString{}))
+		return s.ListEnd()
+	}
+	b, err := s.Bytes()
+	if err != nil {
+		return err
+	}
+	val.Set(reflect.ValueOf(nullString{String: string(b), Valid: true}))
+	return nil
+}
+
+type nullStringFields struct {
+	A    nullString
+	B    *nullString `rlp:"nil"`
+	C    []nullString
+	Tail []nullString `rlp:"tail"`
+}
+
+func TestRegisteredTypeEncode(t *testing.T) {
+	tests := []struct {
+		val    interface{}
+		output string
+	}{
+		{val: nullString{}, output: "C0"},
+		{val: nullString{String: "abc", Valid: true}, output: "83616263"},
+		{val: &nullString{String: "", Valid: true}, output: "80"},
+		{val: []nullString{{}, {"a", true}}, output: "C2C061"},
+		{val: map[string]nullString{"k": {"v", true}}, output: "C3C26B76"},
+		{
+			val:    &nullStringFields{A: nullString{"a", true}, C: []nullString{{}}, Tail: []nullString{{"b", true}}},
+			output: "C561C0C1C062",
+		},
+	}
+	for i, test := range tests {
+		output, err := EncodeToBytes(test.val)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(output, unhex(test.output)) {
+			t.Errorf("test %d: output mismatch\ngot  %X\nwant %s", i, output, test.output)
+		}
+	}
+}
+
+func TestRegisteredTypeDecode(t *testing.T) {
+	tests := []decodeTest{
+		{input: "C0", ptr: new(nullString), value: nullString{}},
+		{input: "83616263", ptr: new(nullString), value: nullString{"abc", true}},
+		{input: "C2C061", ptr: new([]nullString), value: []nullString{{}, {"a", true}}},
+		{input: "C3C26B76", ptr: new(map[string]nullString), value: map[string]nullString{"k": {"v", true}}},
+		{
+			input: "C561C0C1C062",
+			ptr:   new(nullStringFields),
+			value: nullStringFields{A: nullString{"a", true}, C: []nullString{{}}, Tail: []nullString{{"b", true}}},
+		},
+		{
+			input: "C66162C1C06162",
+			ptr:   new(nullStringFields),
+			value: nullStringFields{A: nullString{"a", true}, B: &nullString{"b", true}, C: []nullString{{}}, Tail: []nullString{{"a", true}, {"b", true}}},
+		},
+		{input: "C101", ptr: new(nullString), error: "rlp: invalid null string"},
+		{
+			// The "nil" tag expects an empty list for *nullString.
+			input: "C46180C0C0",
+			ptr:   new(nullStringFields),
+			error: "rlp: wrong kind of empty value (got String, want List) for *rlp.nullString, decoding into (rlp.nullStringFields).B (String at offset 0x2)",
+		},
+	}
+	for i, test := range tests {
+		err := DecodeBytes(unhex(test.input), test.ptr)
+		if test.error != "" {
+			if fmt.Sprint(err) != test.error {
+				t.Errorf("test %d: error mismatch\ngot  %v\nwant %s", i, err, test.error)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if got := reflect.ValueOf(test.ptr).Elem().Interface(); !reflect.DeepEqual(got, test.value) {
+			t.Errorf("test %d: value mismatch\ngot  %#v\nwant %#v", i, got, test.value)
+		}
+	}
+}
+
+type registeredLater struct {
+	x uint
+}
+
+type registeredLaterField struct {
+	F registeredLater
+}
+
+func TestRegisterTypeResetsCache(t *testing.T) {
+	v := registeredLaterField{F: registeredLater{x: 5}}
+	// Before registration, F encodes as a struct without public fields.
+	if output, _ := EncodeToBytes(&v); !bytes.Equal(output, unhex("C1C0")) {
+		t.Fatalf("wrong output before registration: %X", output)
+	}
+
+	RegisterType(reflect.TypeOf(registeredLater{}),
+		func(w EncoderBuffer, val reflect.Value) error {
+			w.WriteUint64(uint64(val.Interface().(registeredLater).x))
+			return nil
+		},
+		func(s *Stream, val reflect.Value) error {
+			x, err := s.Uint64()
+			val.Set(reflect.ValueOf(registeredLater{x: uint(x)}))
+			return err
+		},
+	)
+	defer func() {
+		typeCacheMutex.Lock()
+		defer typeCacheMutex.Unlock()
+		delete(registry, reflect.TypeOf(registeredLater{}))
+		typeCache = make(map[typekey]*typeinfo)
+	}()
+	output, err := EncodeToBytes(&v)
+	if err != nil || !bytes.Equal(output, unhex("C105")) {
+		t.Fatalf("wrong output after registration: %X, %v", output, err)
+	}
+	var dec registeredLaterField
+	if err := DecodeBytes(output, &dec); err != nil || dec != v {
+		t.Fatalf("wrong decoded value: %+v, %v", dec, err)
+	}
+	// Errors returned by Stream methods get the usual context.
+	err = DecodeBytes(unhex("C1C0"), &dec)
+	want := "rlp: expected input string or byte for rlp.registeredLater, decoding into (rlp.registeredLaterField).F (List at offset 0x1)"
+	if fmt.Sprint(err) != want {
+		t.Fatalf("wrong error: got %v, want %s", err, want)
+	}
+}
+
+func TestRegisterTypePanics(t *testing.T) {
+	writer := func(EncoderBuffer, reflect.Value) error { return nil }
+	decoder := func(*Stream, reflect.Value) error { return nil }
+	tests := []struct {
+		typ     reflect.Type
+		writer  TypeWriter
+		decoder TypeDecoder
+		want    string
+	}{
+		{reflect.TypeOf(nullString{}), writer, decoder, "rlp: RegisterType called twice for type rlp.nullString"},
+		{reflect.TypeOf(new(interface{})).Elem(), writer, decoder, "rlp: can't register interface type interface {}"},
+		{reflect.TypeOf(uint(0)), nil, decoder, "rlp: RegisterType called with nil argument"},
+		{nil, writer, decoder, "rlp: RegisterType called with nil argument"},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if r := recover(); fmt.Sprint(r) != test.want {
+					t.Errorf("wrong panic: got %v, want %s", r, test.want)
+				}
+			}()
+			RegisterType(test.typ, test.writer, test.decoder)
+		}()
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema describes the layout of an RLP value for decoding without the Go
+// type that produced it. Schemas are written in a small language:
+//
+//	{name: string, balance: bigint, tags: [string], owner: {id: uint, since: time}}
+//
+// A record {...} is an RLP list whose elements are named fields, and [T]
+// is a list of values of type T. The root of a schema must be a record.
+// Trailing fields can be marked optional with a question mark, as in
+// "note?: string"; they may be missing from the input like struct fields
+// with the "optional" tag.
+//
+// The basic types and the Go types they decode into are:
+//
+//	uint, uint8, uint16, uint32, uint64    uint64, or the sized type
+//	int, int8, int16, int32, int64         int64, or the sized type
+//	float, float32, float64                float64, or the sized type
+//	bool                                   bool
+//	string                                 string
+//	bytes                                  []byte
+//	bigint                                 *big.Int
+//	time                                   time.Time
+//	raw                                    RawValue
+//	any                                    []byte or []interface{}, like interface{}
+type Schema struct {
+	root *schemaNode
+}
+
+type schemaNode struct {
+	typ    reflect.Type  // Go type of basic values and lists
+	dec    decoder       // decoder of basic values
+	elem   *schemaNode   // element type of lists
+	fields []schemaField // fields of records
+}
+
+type schemaField struct {
+	name     string
+	node     *schemaNode
+	optional bool
+}
+
+var (
+	schemaRecordType = reflect.TypeOf(map[string]interface{}{})
+
+	schemaBasicTypes = map[string]reflect.Type{
+		"uint":    reflect.TypeOf(uint64(0)),
+		"uint8":   reflect.TypeOf(uint8(0)),
+		"uint16":  reflect.TypeOf(uint16(0)),
+		"uint32":  reflect.TypeOf(uint32(0)),
+		"uint64":  reflect.TypeOf(uint64(0)),
+		"int":     reflect.TypeOf(int64(0)),
+		"int8":    reflect.TypeOf(int8(0)),
+		"int16":   reflect.TypeOf(int16(0)),
+		"int32":   reflect.TypeOf(int32(0)),
+		"int64":   reflect.TypeOf(int64(0)),
+		"float":   reflect.TypeOf(float64(0)),
+		"float32": reflect.TypeOf(float32(0)),
+		"float64": reflect.TypeOf(float64(0)),
+		"bool":    reflect.TypeOf(false),
+		"string":  reflect.TypeOf(""),
+		"bytes":   reflect.TypeOf([]byte{}),
+		"bigint":  reflect.TypeOf(new(big.Int)),
+		"time":    reflect.TypeOf(time.Time{}),
+		"raw":     rawValueType,
+		"any":     reflect.TypeOf(new(interface{})).Elem(),
+	}
+)
+
+// ParseSchema parses a schema. See Schema for the syntax.
+func ParseSchema(text string) (*Schema, error) {
+	p := &schemaParser{text: text}
+	p.skipSpace()
+	if p.peek() != '{' {
+		return nil, p.errorf("schema must be a record")
+	}
+	root, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.text) {
+		return nil, p.errorf("unexpected %q after schema", p.text[p.pos])
+	}
+	return &Schema{root: root}, nil
+}
+
+// MustParseSchema is like ParseSchema, but panics if text is invalid.
+func MustParseSchema(text string) *Schema {
+	s, err := ParseSchema(text)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// DecodeWithSchema decodes the RLP value in b according to schema. Records
+// decode into maps from field names to values, lists into []interface{}
+// and basic values into the Go types listed in the documentation of Schema.
+// Optional fields that are absent from the input are left out of the map.
+func DecodeWithSchema(b []byte, schema *Schema) (map[string]interface{}, error) {
+	r := bytes.NewReader(b)
+
+	stream := streamPool.Get().(*Stream)
+	defer streamPool.Put(stream)
+
+	stream.Reset(r, uint64(len(b)))
+	val, err := schema.root.decode(stream)
+	if err != nil {
+		if decErr, ok := err.(*DecodeError); ok {
+			stream.locateError(decErr)
+		}
+		return nil, err
+	}
+	if r.Len() > 0 {
+		return nil, ErrMoreThanOneValue
+	}
+	return val.(map[string]interface{}), nil
+}
+
+func (n *schemaNode) decode(s *Stream) (interface{}, error) {
+	switch {
+	case n.dec != nil:
+		val := reflect.New(n.typ).Elem()
+		if err := n.dec(s, val); err != nil {
+			return nil, err
+		}
+		return val.Interface(), nil
+	case n.elem != nil:
+		return n.decodeList(s)
+	default:
+		return n.decodeRecord(s)
+	}
+}
+
+func (n *schemaNode) decodeList(s *Stream) (interface{}, error) {
+	if _, err := s.List(); err != nil {
+		return nil, wrapStreamError(err, ifsliceType)
+	}
+	list := []interface{}{}
+	for i := 0; ; i++ {
+		v, err := n.elem.decode(s)
+		if err == ErrEOL {
+			break
+		} else if err != nil {
+			return nil, addErrorContext(err, fmt.Sprint("[", i, "]"))
+		}
+		list = append(list, v)
+	}
+	return list, s.listEnd(ifsliceType)
+}
+
+func (n *schemaNode) decodeRecord(s *Stream) (interface{}, error) {
+	if _, err := s.List(); err != nil {
+		return nil, wrapStreamError(err, schemaRecordType)
+	}
+	record := make(map[string]interface{}, len(n.fields))
+	for _, f := range n.fields {
+		v, err := f.node.decode(s)
+		if err == ErrEOL {
+			if f.optional {
+				break
+			}
+			err := s.listError("too few elements", schemaRecordType)
+			err.Path = []string{"." + f.name}
+			return nil, err
+		} else if err != nil {
+			return nil, addErrorContext(err, "."+f.name)
+		}
+		record[f.name] = v
+	}
+	return record, s.listEnd(schemaRecordType)
+}
+
+// schemaParser parses the schema language.
+type schemaParser struct {
+	text string
+	pos  int
+}
+
+func (p *schemaParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("rlp: invalid schema at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *schemaParser) skipSpace() {
+	for p.pos < len(p.text) && strings.IndexByte(" \t\r\n", p.text[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// peek returns the next character, or zero at the end of the input.
+func (p *schemaParser) peek() byte {
+	if p.pos < len(p.text) {
+		return p.text[p.pos]
+	}
+	return 0
+}
+
+func (p *schemaParser) expect(c byte) error {
+	p.skipSpace()
+	if p.peek() != c {
+		return p.unexpected(fmt.Sprintf("%q", c))
+	}
+	p.pos++
+	return nil
+}
+
+func (p *schemaParser) unexpected(want string) error {
+	if p.pos == len(p.text) {
+		return p.errorf("unexpected end of schema, want %s", want)
+	}
+	return p.errorf("unexpected %q, want %s", p.text[p.pos], want)
+}
+
+func (p *schemaParser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			break
+		}
+		p.pos++
+	}
+	return p.text[start:p.pos]
+}
+
+func (p *schemaParser) parseType() (*schemaNode, error) {
+	p.skipSpace()
+	switch p.peek() {
+	case '[':
+		p.pos++
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(']'); err != nil {
+			return nil, err
+		}
+		return &schemaNode{typ: ifsliceType, elem: elem}, nil
+	case '{':
+		p.pos++
+		return p.parseRecord()
+	}
+	start := p.pos
+	name := p.ident()
+	if name == "" {
+		return nil, p.unexpected("type")
+	}
+	typ, ok := schemaBasicTypes[name]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown type %q", name)
+	}
+	dec, err := cachedDecoder(typ)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaNode{typ: typ, dec: dec}, nil
+}
+
+func (p *schemaParser) parseRecord() (*schemaNode, error) {
+	n := &schemaNode{typ: schemaRecordType}
+	seen := make(map[string]bool)
+	if p.skipSpace(); p.peek() == '}' {
+		p.pos++
+		return n, nil
+	}
+	for {
+		p.skipSpace()
+		start := p.pos
+		f := schemaField{name: p.ident()}
+		if f.name == "" {
+			return nil, p.unexpected("field name")
+		}
+		if seen[f.name] {
+			p.pos = start
+			return nil, p.errorf("duplicate field %q", f.name)
+		}
+		seen[f.name] = true
+		if p.skipSpace(); p.peek() == '?' {
+			p.pos++
+			f.optional = true
+		} else if len(n.fields) > 0 && n.fields[len(n.fields)-1].optional {
+			p.pos = start
+			return nil, p.errorf("field %q must be optional because preceding field %q is optional", f.name, n.fields[len(n.fields)-1].name)
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		node, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		f.node = node
+		n.fields = append(n.fields, f)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return n, nil
+		default:
+			return nil, p.unexpected(`"," or "}"`)
+		}
+	}
+}
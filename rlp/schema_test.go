@@ -0,0 +1,157 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeWithSchema(t *testing.T) {
+	type owner struct {
+		ID    uint
+		Since time.Time
+	}
+	type account struct {
+		Name    string
+		Balance *big.Int
+		Delta   int64
+		Rate    float64
+		Small   int8
+		Active  bool
+		Tags    []string
+		Owner   owner
+		Raw     RawValue
+		Extra   []interface{}
+		Note    string `rlp:"optional"`
+	}
+	since := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	input, err := EncodeToBytes(&account{
+		Name:    "alice",
+		Balance: big.NewInt(1000),
+		Delta:   -5,
+		Rate:    0.25,
+		Small:   -128,
+		Active:  true,
+		Tags:    []string{"a", "b"},
+		Owner:   owner{ID: 7, Since: since},
+		Raw:     unhex("C20102"),
+		Extra:   []interface{}{uint(1), []interface{}{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := MustParseSchema(`{
+		name: string, balance: bigint, delta: int, rate: float, small: int8, active: bool,
+		tags: [string],
+		owner: {id: uint, since: time},
+		raw: raw, extra: any,
+		note?: string
+	}`)
+	got, err := DecodeWithSchema(input, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"name":    "alice",
+		"balance": big.NewInt(1000),
+		"delta":   int64(-5),
+		"rate":    0.25,
+		"small":   int8(-128),
+		"active":  true,
+		"tags":    []interface{}{"a", "b"},
+		"owner":   map[string]interface{}{"id": uint64(7), "since": since},
+		"raw":     RawValue(unhex("C20102")),
+		"extra":   []interface{}{[]byte{1}, []interface{}{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot  %#v\nwant %#v", got, want)
+	}
+}
+
+func TestDecodeWithSchemaErrors(t *testing.T) {
+	tests := []struct {
+		schema, input string
+		err           string
+	}{
+		{"{a: uint}", "C0", "rlp: too few elements for map[string]interface {}, decoding into .a (List at offset 0x0)"},
+		{"{a: uint}", "C20102", "rlp: input list has too many elements for map[string]interface {} (List at offset 0x0)"},
+		{"{a: uint}", "01", "rlp: expected input list for map[string]interface {} (Byte at offset 0x0)"},
+		{"{a: uint}", "C101C0", ErrMoreThanOneValue.Error()},
+		{"{a: uint8}", "C3820100", "rlp: input string too long for uint8, decoding into .a (String at offset 0x1)"},
+		{"{a: int8}", "C3820080", "rlp: integer out of range for int8, decoding into .a (String at offset 0x1)"},
+		{"{a: [uint]}", "C3C2C001", "rlp: expected input string or byte for uint64, decoding into .a[0] (List at offset 0x2)"},
+		{"{a: float32}", "C9883FB999999999999A", "rlp: value not representable for float32, decoding into .a (String at offset 0x1)"},
+	}
+	for _, test := range tests {
+		_, err := DecodeWithSchema(unhex(test.input), MustParseSchema(test.schema))
+		if fmt.Sprint(err) != test.err {
+			t.Errorf("schema %s, input %s: wrong error\ngot  %v\nwant %s", test.schema, test.input, err, test.err)
+		}
+	}
+}
+
+func TestDecodeWithSchemaOptional(t *testing.T) {
+	schema := MustParseSchema("{a: uint, b?: int, c?: float}")
+	tests := []struct {
+		input string
+		want  map[string]interface{}
+	}{
+		{"C101", map[string]interface{}{"a": uint64(1)}},
+		{"C401820101", map[string]interface{}{"a": uint64(1), "b": int64(-1)}},
+		{"CB0180883FF0000000000000", map[string]interface{}{"a": uint64(1), "b": int64(0), "c": 1.0}},
+	}
+	for _, test := range tests {
+		got, err := DecodeWithSchema(unhex(test.input), schema)
+		if err != nil {
+			t.Errorf("input %s: %v", test.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("input %s: got %#v, want %#v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	tests := []struct {
+		schema, err string
+	}{
+		{"", "rlp: invalid schema at offset 0: schema must be a record"},
+		{"[uint]", "rlp: invalid schema at offset 0: schema must be a record"},
+		{"{a: uint", `rlp: invalid schema at offset 8: unexpected end of schema, want "," or "}"`},
+		{"{a uint}", `rlp: invalid schema at offset 3: unexpected 'u', want ':'`},
+		{"{a: number}", `rlp: invalid schema at offset 4: unknown type "number"`},
+		{"{a: [uint}", `rlp: invalid schema at offset 9: unexpected '}', want ']'`},
+		{"{a: uint, a: int}", `rlp: invalid schema at offset 10: duplicate field "a"`},
+		{"{a?: uint, b: int}", `rlp: invalid schema at offset 11: field "b" must be optional because preceding field "a" is optional`},
+		{"{a: uint} x", `rlp: invalid schema at offset 10: unexpected 'x' after schema`},
+		{"{, }", `rlp: invalid schema at offset 1: unexpected ',', want field name`},
+	}
+	for _, test := range tests {
+		_, err := ParseSchema(test.schema)
+		if fmt.Sprint(err) != test.err {
+			t.Errorf("schema %q: wrong error\ngot  %v\nwant %s", test.schema, err, test.err)
+		}
+	}
+	if _, err := ParseSchema("{}"); err != nil {
+		t.Errorf("empty record: %v", err)
+	}
+}
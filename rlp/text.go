@@ -0,0 +1,238 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseText compiles the text notation of an RLP value to its encoding.
+// The notation is meant for writing test fixtures by hand:
+//
+//	["0x01", ["abc", 5], []]
+//
+// Lists are written in square brackets, with elements separated by commas.
+// A trailing comma is allowed. Quoted strings use Go syntax and are encoded
+// as RLP strings, except that a string starting with 0x holds hexadecimal
+// bytes. Unquoted numbers are non-negative integers in decimal or with a
+// 0x, 0o or 0b prefix, and are encoded like a big.Int. Text from // to the
+// end of a line is a comment.
+//
+// Errors report the line and column of the invalid input.
+func ParseText(text string) ([]byte, error) {
+	p := &textParser{text: text}
+	w := encbufPool.Get().(*encbuf)
+	defer encbufPool.Put(w)
+	w.reset()
+
+	if err := p.parseValue(w); err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.text) {
+		return nil, p.errorf("unexpected %q after value", p.text[p.pos])
+	}
+	return w.toBytes(), nil
+}
+
+// FormatText renders a single RLP value in the notation accepted by
+// ParseText. Strings of printable ASCII characters are shown quoted, all
+// other strings in hex. Integers can't be told apart from strings in the
+// encoding, so they are shown as strings too.
+//
+// For example, the encoding of []interface{}{"cat", uint(1), []uint{}}
+// formats as
+//
+//	["cat", "0x01", []]
+//
+// FormatText returns ErrMoreThanOneValue if b contains data after the value.
+func FormatText(b []byte) (string, error) {
+	var out strings.Builder
+	rest, err := textValue(&out, b)
+	if err != nil {
+		return "", err
+	}
+	if len(rest) > 0 {
+		return "", ErrMoreThanOneValue
+	}
+	return out.String(), nil
+}
+
+func textValue(out *strings.Builder, b []byte) (rest []byte, err error) {
+	k, content, rest, err := Split(b)
+	if err != nil {
+		return b, err
+	}
+	if k != List {
+		if len(content) == 0 || isPrintable(content) && !strings.HasPrefix(string(content), "0x") {
+			out.WriteString(strconv.Quote(string(content)))
+		} else {
+			out.WriteString(`"0x`)
+			out.WriteString(hex.EncodeToString(content))
+			out.WriteByte('"')
+		}
+		return rest, nil
+	}
+	out.WriteByte('[')
+	for i := 0; len(content) > 0; i++ {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		if content, err = textValue(out, content); err != nil {
+			return b, err
+		}
+	}
+	out.WriteByte(']')
+	return rest, nil
+}
+
+// textParser parses the text notation.
+type textParser struct {
+	text string
+	pos  int
+}
+
+func (p *textParser) errorf(format string, args ...interface{}) error {
+	line := 1 + strings.Count(p.text[:p.pos], "\n")
+	col := 1 + utf8.RuneCountInString(p.text[strings.LastIndexByte(p.text[:p.pos], '\n')+1:p.pos])
+	return fmt.Errorf("rlp: invalid text at line %d, column %d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips whitespace and comments.
+func (p *textParser) skipSpace() {
+	for p.pos < len(p.text) {
+		switch {
+		case strings.IndexByte(" \t\r\n", p.text[p.pos]) >= 0:
+			p.pos++
+		case strings.HasPrefix(p.text[p.pos:], "//"):
+			if end := strings.IndexByte(p.text[p.pos:], '\n'); end >= 0 {
+				p.pos += end + 1
+			} else {
+				p.pos = len(p.text)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next character, or zero at the end of the input.
+func (p *textParser) peek() byte {
+	if p.pos < len(p.text) {
+		return p.text[p.pos]
+	}
+	return 0
+}
+
+func (p *textParser) unexpected(want string) error {
+	if p.pos == len(p.text) {
+		return p.errorf("unexpected end of text, want %s", want)
+	}
+	return p.errorf("unexpected %q, want %s", p.text[p.pos], want)
+}
+
+func (p *textParser) parseValue(w *encbuf) error {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '[':
+		p.pos++
+		return p.parseList(w)
+	case c == '"':
+		return p.parseString(w)
+	case c >= '0' && c <= '9':
+		return p.parseInteger(w)
+	case c == '-':
+		return p.errorf("negative integers are not supported")
+	default:
+		return p.unexpected("value")
+	}
+}
+
+func (p *textParser) parseList(w *encbuf) error {
+	lh := w.list()
+	for {
+		if p.skipSpace(); p.peek() == ']' {
+			p.pos++
+			break
+		}
+		if err := p.parseValue(w); err != nil {
+			return err
+		}
+		p.skipSpace()
+		if p.peek() == ',' {
+			p.pos++
+		} else if p.peek() != ']' {
+			return p.unexpected(`"," or "]"`)
+		}
+	}
+	w.listEnd(lh)
+	return nil
+}
+
+func (p *textParser) parseString(w *encbuf) error {
+	start := p.pos
+	end := start + 1
+	for ; end < len(p.text) && p.text[end] != '"'; end++ {
+		switch p.text[end] {
+		case '\\':
+			end++
+		case '\n':
+			return p.errorf("newline in string")
+		}
+	}
+	if end >= len(p.text) {
+		return p.errorf("unterminated string")
+	}
+	lit := p.text[start : end+1]
+	if strings.HasPrefix(lit, `"0x`) {
+		b, err := hex.DecodeString(lit[3 : len(lit)-1])
+		if err != nil {
+			return p.errorf("invalid hex string %s", lit)
+		}
+		w.encodeString(b)
+	} else {
+		s, err := strconv.Unquote(lit)
+		if err != nil {
+			return p.errorf("invalid string %s", lit)
+		}
+		w.writeString(s)
+	}
+	p.pos = end + 1
+	return nil
+}
+
+func (p *textParser) parseInteger(w *encbuf) error {
+	start := p.pos
+	for p.pos < len(p.text) && isTextIntChar(p.text[p.pos]) {
+		p.pos++
+	}
+	lit := p.text[start:p.pos]
+	i, ok := new(big.Int).SetString(lit, 0)
+	if !ok {
+		p.pos = start
+		return p.errorf("invalid integer %q", lit)
+	}
+	return writeBigInt(i, w)
+}
+
+func isTextIntChar(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseText(t *testing.T) {
+	tests := []struct {
+		text, output, err string
+	}{
+		{text: `""`, output: "80"},
+		{text: `"0x"`, output: "80"},
+		{text: `"0x01"`, output: "01"},
+		{text: `"0x80"`, output: "8180"},
+		{text: `"0x0001"`, output: "820001"},
+		{text: `"abc"`, output: "83616263"},
+		{text: `"\x00\"\n"`, output: "8300220A"},
+		{text: `0`, output: "80"},
+		{text: `5`, output: "05"},
+		{text: `127`, output: "7F"},
+		{text: `128`, output: "8180"},
+		{text: `0x0102`, output: "820102"},
+		{text: `1_000_000`, output: "830F4240"},
+		{text: `0x100000000000000000000000000000000`, output: "9101" + strings.Repeat("00", 16)},
+		{text: `[]`, output: "C0"},
+		{text: `["0x01", ["abc", 5], []]`, output: "C801C583616263 05C0"},
+		{text: "[\n  1, // one\n  2,\n]\n// done", output: "C20102"},
+		{text: `[[], [[]], [[], [[]]]]`, output: "C7C0C1C0C3C0C1C0"},
+		{text: `"` + strings.Repeat("a", 56) + `"`, output: "B838" + strings.Repeat("61", 56)},
+
+		// Errors.
+		{text: ``, err: "rlp: invalid text at line 1, column 1: unexpected end of text, want value"},
+		{text: `[1 2]`, err: `rlp: invalid text at line 1, column 4: unexpected '2', want "," or "]"`},
+		{text: `[1,`, err: "rlp: invalid text at line 1, column 4: unexpected end of text, want value"},
+		{text: `[,]`, err: "rlp: invalid text at line 1, column 2: unexpected ',', want value"},
+		{text: `1 2`, err: "rlp: invalid text at line 1, column 3: unexpected '2' after value"},
+		{text: `-1`, err: "rlp: invalid text at line 1, column 1: negative integers are not supported"},
+		{text: `12ab`, err: `rlp: invalid text at line 1, column 1: invalid integer "12ab"`},
+		{text: "[\n  \"0x012\"]", err: `rlp: invalid text at line 2, column 3: invalid hex string "0x012"`},
+		{text: "[\n  \"0xzz\"]", err: `rlp: invalid text at line 2, column 3: invalid hex string "0xzz"`},
+		{text: `["é", "abc]`, err: "rlp: invalid text at line 1, column 7: unterminated string"},
+		{text: "\"a\nb\"", err: "rlp: invalid text at line 1, column 1: newline in string"},
+		{text: `"\q"`, err: `rlp: invalid text at line 1, column 1: invalid string "\q"`},
+		{text: `abc`, err: "rlp: invalid text at line 1, column 1: unexpected 'a', want value"},
+	}
+	for _, test := range tests {
+		output, err := ParseText(test.text)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: error mismatch\ngot  %v\nwant %s", test.text, err, test.err)
+			}
+			continue
+		}
+		want := unhex(strings.ReplaceAll(test.output, " ", ""))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.text, err)
+		} else if !bytes.Equal(output, want) {
+			t.Errorf("%q: output mismatch\ngot  %X\nwant %X", test.text, output, want)
+		}
+	}
+}
+
+func TestFormatText(t *testing.T) {
+	tests := []struct {
+		input, output, err string
+	}{
+		{input: "80", output: `""`},
+		{input: "01", output: `"0x01"`},
+		{input: "83636174", output: `"cat"`},
+		{input: "8430786161", output: `"0x30786161"`}, // "0xaa" must not be read back as hex
+		{input: "8322615C", output: `"\"a\\"`},
+		{input: "C0", output: `[]`},
+		{input: "C801C583616263 05C0", output: `["0x01", ["abc", "0x05"], []]`},
+		{input: "C7C0C1C0C3C0C1C0", output: `[[], [[]], [[], [[]]]]`},
+		{input: "0102", err: ErrMoreThanOneValue.Error()},
+		{input: "C3C201", err: ErrValueTooLarge.Error()},
+	}
+	for _, test := range tests {
+		output, err := FormatText(unhex(strings.ReplaceAll(test.input, " ", "")))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: error mismatch\ngot  %v\nwant %s", test.input, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.input, err)
+		} else if output != test.output {
+			t.Errorf("%s: output mismatch\ngot  %s\nwant %s", test.input, output, test.output)
+		}
+	}
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	for i, test := range encTests {
+		if test.error != "" || test.output == "" {
+			continue
+		}
+		enc := fixture(test.output)
+		if _, err := ToJSON(enc); err != nil {
+			continue // some tests write invalid RLP through RawValue
+		}
+		text, err := FormatText(enc)
+		if err != nil {
+			t.Errorf("test %d: FormatText error: %v", i, err)
+			continue
+		}
+		dec, err := ParseText(text)
+		if err != nil {
+			t.Errorf("test %d: ParseText error: %v\ntext: %s", i, err, text)
+		} else if !bytes.Equal(dec, enc) {
+			t.Errorf("test %d: round trip mismatch\ntext: %s\ngot  %X\nwant %X", i, text, dec, enc)
+		}
+	}
+}
+
+func ExampleParseText() {
+	b, err := ParseText(`["0x01", ["abc", 5], []]`)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%X\n", b)
+	text, _ := FormatText(b)
+	fmt.Println(text)
+	// Output:
+	// C801C58361626305C0
+	// ["0x01", ["abc", "0x05"], []]
+}
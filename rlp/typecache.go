@@ -49,8 +49,16 @@ type tags struct {
 	// of slice type.
 	tail bool
 
+	// rlp:"optional" allows for a field to be missing in the input list.
+	// If this is set, all subsequent fields must also be optional.
+	optional bool
+
 	// rlp:"-" ignores fields.
 	ignored bool
+
+	// rlp:"inline" flattens the fields of an embedded struct into the
+	// list of the enclosing struct.
+	inline bool
 }
 
 // typekey is the key of a type in typeCache. It includes the struct tags because
@@ -104,36 +112,88 @@ func cachedTypeInfo1(typ reflect.Type, tags tags) *typeinfo {
 }
 
 type field struct {
-	index int
-	info  *typeinfo
+	index    []int // index sequence for reflect.Value.FieldByIndex
+	info     *typeinfo
+	optional bool
 }
 
 func structFields(typ reflect.Type) (fields []field, err error) {
+	var fs fieldScanner
+	if err := fs.scan(typ, nil); err != nil {
+		return nil, err
+	}
+	return fs.fields, nil
+}
+
+// fieldScanner collects the encoded fields of a struct type. Fields of
+// embedded structs with the "inline" tag are collected in place.
+type fieldScanner struct {
+	fields        []field
+	firstOptional string
+	tail          string
+}
+
+func (fs *fieldScanner) scan(typ reflect.Type, index []int) error {
 	lastPublic := lastPublicField(typ)
 	for i := 0; i < typ.NumField(); i++ {
 		if f := typ.Field(i); f.PkgPath == "" { // exported
 			tags, err := parseStructTag(typ, i, lastPublic)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			if tags.ignored {
 				continue
 			}
+			// A "tail" field must be the last field, even when it comes
+			// from an inlined struct.
+			if fs.tail != "" {
+				return structTagError{typ, fs.tail, "tail", "must be on last field"}
+			}
+			fieldIndex := append(index[:len(index):len(index)], i)
+			if tags.inline {
+				if err := fs.scan(f.Type, fieldIndex); err != nil {
+					return err
+				}
+				continue
+			}
+			// Once a field is optional, all following fields must
+			// be optional too. A "tail" field counts as optional.
+			if tags.optional || tags.tail {
+				if fs.firstOptional == "" {
+					fs.firstOptional = f.Name
+				}
+			} else if fs.firstOptional != "" {
+				msg := fmt.Sprintf("must be optional because preceding field %q is optional", fs.firstOptional)
+				return structTagError{typ, f.Name, f.Tag.Get("rlp"), msg}
+			}
+			if tags.tail {
+				fs.tail = f.Name
+			}
 			info := cachedTypeInfo1(f.Type, tags)
-			fields = append(fields, field{i, info})
+			fs.fields = append(fs.fields, field{fieldIndex, info, tags.optional})
+		}
+	}
+	return nil
+}
+
+// firstOptionalField returns the index of the first field with "optional" tag.
+func firstOptionalField(fields []field) int {
+	for i, f := range fields {
+		if f.optional {
+			return i
 		}
 	}
-	return fields, nil
+	return len(fields)
 }
 
 type structFieldError struct {
 	typ   reflect.Type
-	field int
+	field []int
 	err   error
 }
 
 func (e structFieldError) Error() string {
-	return fmt.Sprintf("%v (struct field %v.%s)", e.err, e.typ, e.typ.Field(e.field).Name)
+	return fmt.Sprintf("%v (struct field %v.%s)", e.err, e.typ, e.typ.FieldByIndex(e.field).Name)
 }
 
 type structTagError struct {
@@ -166,14 +226,33 @@ func parseStructTag(typ reflect.Type, fi, lastPublic int) (tags, error) {
 			case "nilList":
 				ts.nilKind = List
 			}
+		case "optional":
+			ts.optional = true
+			if ts.tail {
+				return ts, structTagError{typ, f.Name, t, `also has "tail" tag`}
+			}
+			if ts.inline {
+				return ts, structTagError{typ, f.Name, t, `also has "inline" tag`}
+			}
 		case "tail":
 			ts.tail = true
+			if ts.optional {
+				return ts, structTagError{typ, f.Name, t, `also has "optional" tag`}
+			}
 			if fi != lastPublic {
 				return ts, structTagError{typ, f.Name, t, "must be on last field"}
 			}
 			if f.Type.Kind() != reflect.Slice {
 				return ts, structTagError{typ, f.Name, t, "field type is not slice"}
 			}
+		case "inline":
+			ts.inline = true
+			if !f.Anonymous || f.Type.Kind() != reflect.Struct {
+				return ts, structTagError{typ, f.Name, t, "field is not an embedded struct"}
+			}
+			if ts.optional {
+				return ts, structTagError{typ, f.Name, t, `also has "optional" tag`}
+			}
 		default:
 			return ts, fmt.Errorf("rlp: unknown struct tag %q on %v.%s", t, typ, f.Name)
 		}
@@ -200,7 +279,7 @@ func (i *typeinfo) generate(typ reflect.Type, tags tags) {
 // as an empty string or empty list.
 func defaultNilKind(typ reflect.Type) Kind {
 	k := typ.Kind()
-	if isUint(k) || k == reflect.String || k == reflect.Bool || isByteArray(typ) {
+	if isUint(k) || k == reflect.String || k == reflect.Bool || isByteArray(typ) || isBigEndianInteger(typ) {
 		return String
 	}
 	return List
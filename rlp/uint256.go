@@ -0,0 +1,166 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"encoding/binary"
+	"math/big"
+	"reflect"
+)
+
+// BigEndianInteger is implemented by fixed-width unsigned integer types
+// wider than 64 bits. Such types are encoded like big.Int, as the big endian
+// representation of the value without leading zero bytes, but can be
+// encoded and decoded without allocating. Decoding rejects values wider
+// than the type.
+//
+// The methods are looked up on pointers, so that SetBigEndian can modify
+// the value.
+type BigEndianInteger interface {
+	// BigEndianSize returns the width of the type in bytes. It must return
+	// the same value for all values of the type.
+	BigEndianSize() int
+	// PutBigEndian writes the value into b as a big endian number. b is
+	// exactly BigEndianSize bytes long.
+	PutBigEndian(b []byte)
+	// SetBigEndian sets the value to the big endian number in b. b is at
+	// most BigEndianSize bytes long.
+	SetBigEndian(b []byte)
+}
+
+var bigEndianIntegerInterface = reflect.TypeOf(new(BigEndianInteger)).Elem()
+
+func isBigEndianInteger(typ reflect.Type) bool {
+	return typ.Kind() != reflect.Ptr && reflect.PtrTo(typ).Implements(bigEndianIntegerInterface)
+}
+
+func makeBigEndianWriter(typ reflect.Type) writer {
+	return func(val reflect.Value, w *encbuf) error {
+		if !val.CanAddr() {
+			// The methods need a pointer.
+			// Make the value addressable by copying.
+			copy := reflect.New(typ).Elem()
+			copy.Set(val)
+			val = copy
+		}
+		w.writeBigEndian(val.Addr().Interface().(BigEndianInteger))
+		return nil
+	}
+}
+
+func (w *encbuf) writeBigEndian(i BigEndianInteger) {
+	size := i.BigEndianSize()
+	if cap(w.bigbuf) < size {
+		w.bigbuf = make([]byte, size)
+	}
+	b := w.bigbuf[:size]
+	i.PutBigEndian(b)
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	w.encodeString(b)
+}
+
+func decodeBigEndian(s *Stream, val reflect.Value) error {
+	err := s.ReadBigEndian(val.Addr().Interface().(BigEndianInteger))
+	return wrapStreamError(err, val.Type())
+}
+
+// ReadBigEndian decodes an unsigned integer into i. The input must be
+// an RLP string holding a canonical integer that fits into i.
+func (s *Stream) ReadBigEndian(i BigEndianInteger) error {
+	kind, size, err := s.Kind()
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case Byte:
+		if s.byteval == 0 {
+			return ErrCanonInt
+		}
+		s.kind = -1 // rearm Kind
+		s.bigbuf = append(s.bigbuf[:0], s.byteval)
+		i.SetBigEndian(s.bigbuf)
+		return nil
+	case String:
+		if size > uint64(i.BigEndianSize()) {
+			return errUintOverflow
+		}
+		if uint64(cap(s.bigbuf)) < size {
+			s.bigbuf = make([]byte, size)
+		}
+		b := s.bigbuf[:size]
+		if err := s.readFull(b); err != nil {
+			return err
+		}
+		switch {
+		case size > 0 && b[0] == 0:
+			return ErrCanonInt
+		case size == 1 && b[0] < 128:
+			return ErrCanonSize
+		}
+		i.SetBigEndian(b)
+		return nil
+	default:
+		return ErrExpectedString
+	}
+}
+
+// Uint256 is a 256-bit unsigned integer implementing BigEndianInteger.
+// The words are stored least significant first, so x[0] holds the low
+// 64 bits of the value.
+type Uint256 [4]uint64
+
+// Uint256FromBig converts b to a Uint256. The result is false if b is
+// negative or doesn't fit into 256 bits.
+func Uint256FromBig(b *big.Int) (Uint256, bool) {
+	var x Uint256
+	if b.Sign() < 0 || b.BitLen() > 256 {
+		return x, false
+	}
+	var buf [32]byte
+	x.SetBigEndian(b.FillBytes(buf[:]))
+	return x, true
+}
+
+// ToBig returns the value of x as a big.Int.
+func (x *Uint256) ToBig() *big.Int {
+	var buf [32]byte
+	x.PutBigEndian(buf[:])
+	return new(big.Int).SetBytes(buf[:])
+}
+
+// BigEndianSize implements BigEndianInteger.
+func (x *Uint256) BigEndianSize() int { return 32 }
+
+// PutBigEndian implements BigEndianInteger.
+func (x *Uint256) PutBigEndian(b []byte) {
+	binary.BigEndian.PutUint64(b[0:8], x[3])
+	binary.BigEndian.PutUint64(b[8:16], x[2])
+	binary.BigEndian.PutUint64(b[16:24], x[1])
+	binary.BigEndian.PutUint64(b[24:32], x[0])
+}
+
+// SetBigEndian implements BigEndianInteger.
+func (x *Uint256) SetBigEndian(b []byte) {
+	var buf [32]byte
+	copy(buf[32-len(b):], b)
+	x[3] = binary.BigEndian.Uint64(buf[0:8])
+	x[2] = binary.BigEndian.Uint64(buf[8:16])
+	x[1] = binary.BigEndian.Uint64(buf[16:24])
+	x[0] = binary.BigEndian.Uint64(buf[24:32])
+}
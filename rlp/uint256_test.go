@@ -0,0 +1,178 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// uint128 is a BigEndianInteger narrower than Uint256.
+type uint128 struct{ hi, lo uint64 }
+
+func (x *uint128) BigEndianSize() int { return 16 }
+
+func (x *uint128) PutBigEndian(b []byte) {
+	binary.BigEndian.PutUint64(b[:8], x.hi)
+	binary.BigEndian.PutUint64(b[8:], x.lo)
+}
+
+func (x *uint128) SetBigEndian(b []byte) {
+	var buf [16]byte
+	copy(buf[16-len(b):], b)
+	x.hi = binary.BigEndian.Uint64(buf[:8])
+	x.lo = binary.BigEndian.Uint64(buf[8:])
+}
+
+var uint256Values = []string{
+	"0", "1", "127", "128", "255", "256", "18446744073709551615", "18446744073709551616",
+	"115792089237316195423570985008687907853269984665640564039457584007913129639935",
+}
+
+func TestUint256EncodeLikeBigInt(t *testing.T) {
+	for _, s := range uint256Values {
+		b, _ := new(big.Int).SetString(s, 10)
+		x, ok := Uint256FromBig(b)
+		if !ok {
+			t.Fatalf("%s: Uint256FromBig failed", s)
+		}
+		if x.ToBig().Cmp(b) != 0 {
+			t.Errorf("%s: ToBig returned %v", s, x.ToBig())
+		}
+		want, _ := EncodeToBytes(b)
+		got, err := EncodeToBytes(x)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: encoding %x, want %x", s, got, want)
+		}
+		var dec Uint256
+		if err := DecodeBytes(got, &dec); err != nil {
+			t.Errorf("%s: decode error: %v", s, err)
+		} else if dec != x {
+			t.Errorf("%s: decoded %v, want %v", s, dec, x)
+		}
+	}
+}
+
+func TestUint256FromBigOverflow(t *testing.T) {
+	tests := []*big.Int{
+		big.NewInt(-1),
+		new(big.Int).Lsh(big.NewInt(1), 256),
+	}
+	for _, b := range tests {
+		if _, ok := Uint256FromBig(b); ok {
+			t.Errorf("Uint256FromBig(%v) succeeded", b)
+		}
+	}
+}
+
+func TestBigEndianIntegerEncoding(t *testing.T) {
+	type withInts struct {
+		A   Uint256
+		B   *Uint256
+		C   *Uint256 `rlp:"nil"`
+		D   []uint128
+		Opt Uint256 `rlp:"optional"`
+	}
+	tests := []struct {
+		val    interface{}
+		output string
+	}{
+		{val: &uint128{hi: 1, lo: 2}, output: "89010000000000000002"},
+		{val: uint128{lo: 0x7F}, output: "7F"},
+		{val: (*Uint256)(nil), output: "80"},
+		{val: &withInts{}, output: "C4808080C0"},
+		{val: &withInts{A: Uint256{5}, B: &Uint256{0, 1}, D: []uint128{{lo: 0x80}}}, output: "CF058901000000000000000080C28180"},
+		{val: &withInts{Opt: Uint256{3: 1}}, output: "DE808080C099" + "01" + strings.Repeat("00", 24)},
+	}
+	for i, test := range tests {
+		got, err := EncodeToBytes(test.val)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(got, unhex(test.output)) {
+			t.Errorf("test %d: output %X, want %s", i, got, test.output)
+		}
+	}
+
+	var dec withInts
+	if err := DecodeBytes(unhex("CF058901000000000000000080C28180"), &dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec.A != (Uint256{5}) || dec.B == nil || *dec.B != (Uint256{0, 1}) || dec.C != nil || len(dec.D) != 1 || dec.D[0] != (uint128{lo: 0x80}) {
+		t.Errorf("wrong decoded value %+v", dec)
+	}
+}
+
+func TestBigEndianIntegerDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		ptr   interface{}
+		err   string
+	}{
+		{"A1" + "01" + fmt.Sprintf("%064x", 0), new(Uint256), "rlp: input string too long for rlp.Uint256 (String at offset 0x0)"},
+		{"91" + "01" + fmt.Sprintf("%032x", 0), new(uint128), "rlp: input string too long for rlp.uint128 (String at offset 0x0)"},
+		{"8200FF", new(Uint256), "rlp: non-canonical integer (leading zero bytes) for rlp.Uint256 (String at offset 0x0)"},
+		{"00", new(Uint256), "rlp: non-canonical integer (leading zero bytes) for rlp.Uint256 (Byte at offset 0x0)"},
+		{"8105", new(Uint256), "rlp: non-canonical size information for rlp.Uint256 (String at offset 0x0)"},
+		{"C0", new(Uint256), "rlp: expected input string or byte for rlp.Uint256 (List at offset 0x0)"},
+	}
+	for i, test := range tests {
+		err := DecodeBytes(unhex(test.input), test.ptr)
+		if fmt.Sprint(err) != test.err {
+			t.Errorf("test %d: wrong error\ngot  %v\nwant %s", i, err, test.err)
+		}
+	}
+}
+
+func TestUint256Allocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are unreliable with the race detector")
+	}
+	x := Uint256{1, 2, 3, 4}
+	enc, _ := EncodeToBytes(&x)
+	r := bytes.NewReader(enc)
+	s := NewStream(r, 0)
+	var dec Uint256
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Reset(enc)
+		s.Reset(r, 0)
+		if err := s.Decode(&dec); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("decoding allocates %v times", allocs)
+	}
+	buf := new(bytes.Buffer)
+	allocs = testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		if err := Encode(buf, &x); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("encoding allocates %v times", allocs)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Versioned is implemented by struct types whose encoding carries a version
+// number. The version is encoded as the first element of the struct's list,
+// before the fields. RLPVersion must return the same constant for all values
+// of the type, including the zero value.
+//
+// When Decode finds a version other than the current one, the remaining
+// elements are decoded in the old layout and converted by the migration
+// registered for that version with RegisterMigration.
+type Versioned interface {
+	RLPVersion() uint
+}
+
+var versionedInterface = reflect.TypeOf(new(Versioned)).Elem()
+
+// structVersion returns the current version of a struct type implementing
+// Versioned.
+func structVersion(typ reflect.Type) (uint64, bool) {
+	if !reflect.PtrTo(typ).Implements(versionedInterface) {
+		return 0, false
+	}
+	v := reflect.New(typ).Interface().(Versioned).RLPVersion()
+	return uint64(v), true
+}
+
+type migration struct {
+	old    reflect.Type  // struct type of the old layout
+	fields []field       // fields of old
+	fn     reflect.Value // func(*old, *current) error
+}
+
+// migrations holds the functions added by RegisterMigration, indexed by
+// the Versioned type and the version they migrate from.
+// It is protected by typeCacheMutex.
+var migrations = make(map[reflect.Type]map[uint64]*migration)
+
+var errorType = reflect.TypeOf(new(error)).Elem()
+
+// RegisterMigration registers a function that converts values encoded with
+// an older version of a Versioned struct type into the current one.
+//
+// migrate must have the signature
+//
+//	func(old *Old, cur *Current) error
+//
+// where Current is the Versioned type and Old is a struct type describing
+// the fields of version from, in the order they were encoded. Struct tags
+// on Old apply as usual. When Decode finds version from, it decodes the
+// fields into a new Old value and calls migrate with it and a zero Current
+// value, which becomes the result of decoding. Errors returned by migrate
+// are passed on by Decode.
+//
+// RegisterMigration should be called during initialization. It panics if
+// migrate has the wrong type, if from is the current version of Current,
+// if Old can't be decoded or if a migration has already been registered
+// for the version.
+func RegisterMigration(from uint, migrate interface{}) {
+	fn := reflect.ValueOf(migrate)
+	ft := fn.Type()
+	if ft.Kind() != reflect.Func || ft.NumIn() != 2 || ft.NumOut() != 1 || ft.Out(0) != errorType ||
+		ft.In(0).Kind() != reflect.Ptr || ft.In(0).Elem().Kind() != reflect.Struct ||
+		ft.In(1).Kind() != reflect.Ptr || ft.In(1).Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("rlp: migration function has type %v, want func(*Old, *Current) error", ft))
+	}
+	old, typ := ft.In(0).Elem(), ft.In(1).Elem()
+	current, ok := structVersion(typ)
+	if !ok {
+		panic(fmt.Sprintf("rlp: can't register migration for %v, it doesn't implement Versioned", typ))
+	}
+	if uint64(from) == current {
+		panic(fmt.Sprintf("rlp: can't register migration from current version %d of %v", from, typ))
+	}
+
+	typeCacheMutex.Lock()
+	defer typeCacheMutex.Unlock()
+	fields, err := structFields(old)
+	if err == nil {
+		for _, f := range fields {
+			if f.info.decoderErr != nil {
+				err = structFieldError{old, f.index, f.info.decoderErr}
+				break
+			}
+		}
+	}
+	if err != nil {
+		panic(fmt.Sprintf("rlp: can't register migration from %v: %v", old, err))
+	}
+	if migrations[typ] == nil {
+		migrations[typ] = make(map[uint64]*migration)
+	}
+	if migrations[typ][uint64(from)] != nil {
+		panic(fmt.Sprintf("rlp: RegisterMigration called twice for version %d of %v", from, typ))
+	}
+	migrations[typ][uint64(from)] = &migration{old, fields, fn}
+}
+
+// Migrate decodes the remaining elements of the current list in the
+// layout of the given version of a Versioned struct type, converts them
+// using the registered migration and stores the result in the value
+// pointed to by val. It then ends the list. Migrate is meant for DecodeRLP
+// methods of Versioned types, such as the ones generated by rlpgen, after
+// they have read an old version number.
+func Migrate(s *Stream, version uint, val interface{}) error {
+	rval := reflect.ValueOf(val)
+	if rval.Kind() != reflect.Ptr || rval.IsNil() {
+		return errNoPointer
+	}
+	return decodeMigrated(s, rval.Type().Elem(), uint64(version), rval.Elem())
+}
+
+func decodeMigrated(s *Stream, typ reflect.Type, version uint64, val reflect.Value) error {
+	typeCacheMutex.RLock()
+	m := migrations[typ][version]
+	typeCacheMutex.RUnlock()
+	if m == nil {
+		return &DecodeError{msg: fmt.Sprintf("unsupported version %d", version), typ: typ}
+	}
+	old := reflect.New(m.old)
+	if err := decodeStructFields(s, old.Elem(), m.old, m.fields); err != nil {
+		return err
+	}
+	cur := reflect.New(typ)
+	if err := m.fn.Call([]reflect.Value{old, cur})[0]; !err.IsNil() {
+		return fmt.Errorf("rlp: can't migrate %v from version %d: %w", typ, version, err.Interface().(error))
+	}
+	val.Set(cur.Elem())
+	return nil
+}
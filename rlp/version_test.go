@@ -0,0 +1,206 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// versionedAccount is the current layout, version 3. The fixtures in
+// testdata/versions hold accounts encoded with each version.
+type versionedAccount struct {
+	Name    string
+	Balance *big.Int
+	Roles   []string
+	Created time.Time `rlp:"optional"`
+}
+
+func (versionedAccount) RLPVersion() uint { return 3 }
+
+// accountV1 is version 1, which stored the balance as uint64.
+type accountV1 struct {
+	Name    string
+	Balance uint64
+}
+
+// accountV2 is version 2, which stored roles as a comma-separated string.
+type accountV2 struct {
+	Name    string
+	Balance *big.Int
+	Roles   string
+}
+
+var errNoName = errors.New("account has no name")
+
+func init() {
+	RegisterMigration(1, func(old *accountV1, cur *versionedAccount) error {
+		if old.Name == "" {
+			return errNoName
+		}
+		cur.Name = old.Name
+		cur.Balance = new(big.Int).SetUint64(old.Balance)
+		return nil
+	})
+	RegisterMigration(2, func(old *accountV2, cur *versionedAccount) error {
+		cur.Name = old.Name
+		cur.Balance = old.Balance
+		cur.Roles = strings.Split(old.Roles, ",")
+		return nil
+	})
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	text, err := ioutil.ReadFile(filepath.Join("testdata", "versions", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := hex.DecodeString(strings.TrimSpace(string(text)))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return b
+}
+
+var versionFixtures = []struct {
+	file string
+	want versionedAccount
+}{
+	{
+		file: "account_v1.hex",
+		want: versionedAccount{Name: "alice", Balance: big.NewInt(1000)},
+	},
+	{
+		file: "account_v2.hex",
+		want: versionedAccount{Name: "bob", Balance: big.NewInt(2000), Roles: []string{"admin", "ops"}},
+	},
+	{
+		file: "account_v3.hex",
+		want: versionedAccount{
+			Name:    "carol",
+			Balance: big.NewInt(3000),
+			Roles:   []string{"dev"},
+			Created: time.Date(2022, 5, 6, 7, 8, 9, 0, time.UTC),
+		},
+	},
+}
+
+func TestDecodeVersionFixtures(t *testing.T) {
+	for _, test := range versionFixtures {
+		var got versionedAccount
+		if err := DecodeBytes(readFixture(t, test.file), &got); err != nil {
+			t.Errorf("%s: %v", test.file, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: wrong result\ngot  %+v\nwant %+v", test.file, got, test.want)
+		}
+	}
+
+	// Versioned types can be nested, and each value is migrated on its own.
+	var all []versionedAccount
+	if err := DecodeBytes(readFixture(t, "accounts_mixed.hex"), &all); err != nil {
+		t.Fatal(err)
+	}
+	for i, test := range versionFixtures {
+		if i >= len(all) || !reflect.DeepEqual(all[i], test.want) {
+			t.Errorf("mixed list: wrong value %d", i)
+		}
+	}
+}
+
+func TestEncodeVersioned(t *testing.T) {
+	want := readFixture(t, "account_v3.hex")
+	enc, err := EncodeToBytes(&versionFixtures[2].want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, want) {
+		t.Errorf("wrong encoding\ngot  %x\nwant %x", enc, want)
+	}
+
+	// Migrated values are written with the current version.
+	var acc versionedAccount
+	if err := DecodeBytes(readFixture(t, "account_v1.hex"), &acc); err != nil {
+		t.Fatal(err)
+	}
+	if enc, _ = EncodeToBytes(&acc); !bytes.Equal(enc, unhex("CB0385616C6963658203E8C0")) {
+		t.Errorf("wrong encoding of migrated value: %x", enc)
+	}
+}
+
+func TestDecodeVersionedErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"C0", "rlp: missing version for rlp.versionedAccount (List at offset 0x0)"},
+		{"C109", "rlp: unsupported version 9 for rlp.versionedAccount (Byte at offset 0x1)"},
+		{"C3820001", "rlp: non-canonical integer (leading zero bytes) for rlp.versionedAccount (String at offset 0x1)"},
+		{"C201C0", "rlp: expected input string or byte for string, decoding into (rlp.versionedAccount).Name (List at offset 0x2)"},
+		{"C20180", "rlp: too few elements for rlp.accountV1 (List at offset 0x0)"},
+		{"C30180" + "05", "rlp: can't migrate rlp.versionedAccount from version 1: account has no name"},
+	}
+	for _, test := range tests {
+		var acc versionedAccount
+		err := DecodeBytes(unhex(test.input), &acc)
+		if fmt.Sprint(err) != test.err {
+			t.Errorf("input %s: wrong error\ngot  %v\nwant %s", test.input, err, test.err)
+		}
+	}
+
+	var acc versionedAccount
+	if err := DecodeBytes(unhex("C3018005"), &acc); !errors.Is(err, errNoName) {
+		t.Errorf("migration error not wrapped: %v", err)
+	}
+}
+
+type unversioned struct{ A uint }
+
+func TestRegisterMigrationPanics(t *testing.T) {
+	tests := []struct {
+		from    uint
+		migrate interface{}
+		msg     string
+	}{
+		{1, func(*accountV1) error { return nil }, "rlp: migration function has type func(*rlp.accountV1) error, want func(*Old, *Current) error"},
+		{1, func(accountV1, *versionedAccount) error { return nil }, "rlp: migration function has type func(rlp.accountV1, *rlp.versionedAccount) error, want func(*Old, *Current) error"},
+		{1, func(*accountV1, *unversioned) error { return nil }, "rlp: can't register migration for rlp.unversioned, it doesn't implement Versioned"},
+		{3, func(*accountV1, *versionedAccount) error { return nil }, "rlp: can't register migration from current version 3 of rlp.versionedAccount"},
+		{1, func(*accountV1, *versionedAccount) error { return nil }, "rlp: RegisterMigration called twice for version 1 of rlp.versionedAccount"},
+		{0, func(*struct{ C chan int }, *versionedAccount) error { return nil }, "rlp: can't register migration from struct { C chan int }: rlp: type chan int is not RLP-serializable (struct field struct { C chan int }.C)"},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if msg := fmt.Sprint(recover()); msg != test.msg {
+					t.Errorf("wrong panic\ngot  %s\nwant %s", msg, test.msg)
+				}
+			}()
+			RegisterMigration(test.from, test.migrate)
+		}()
+	}
+}